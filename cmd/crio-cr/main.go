@@ -1,8 +1,10 @@
 package main
 
 import (
+	"archive/tar"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"sort"
@@ -19,6 +21,43 @@ import (
 	"google.golang.org/grpc"
 )
 
+// podDumpMarker is the name of the manifest file a pod-level checkpoint
+// archive carries at its root, listing the member container archives and
+// their restore order. Its presence distinguishes a pod archive from a
+// plain single-container one. It must match sandbox.podSpecDumpFile, the
+// name Checkpoint actually writes the manifest under.
+const podDumpMarker = "pod.spec.dump"
+
+// archiveIsPod sniffs path for the podDumpMarker manifest, transparently handling
+// the compression formats produced by the checkpoint command.
+func archiveIsPod(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	decompressed, err := archive.DecompressStream(f)
+	if err != nil {
+		return false, err
+	}
+	defer decompressed.Close()
+
+	tr := tar.NewReader(decompressed)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if hdr.Name == podDumpMarker {
+			return true, nil
+		}
+	}
+}
+
 var checkpointCommand = cli.Command{
 	Name:                   "checkpoint",
 	Usage:                  "Checkpoints one or more containers/pods",
@@ -50,6 +89,23 @@ var checkpointCommand = cli.Command{
 			Usage:   "Select compression algorithm (gzip, none, zstd) for checkpoint archive.",
 			Value:   "zstd",
 		},
+		&cli.BoolFlag{
+			Name:  "pod",
+			Usage: "Treat the given IDs as pod sandboxes and checkpoint every container within each, in dependency order.",
+		},
+		&cli.StringFlag{
+			Name:  "image",
+			Usage: "Push the checkpoint as an OCI image NAME[:TAG] instead of (or in addition to) an --export archive.",
+		},
+		&cli.BoolFlag{
+			Name:  "pre-dump",
+			Usage: "Take an iterative memory pre-dump before the final checkpoint, leaving the container running in between.",
+		},
+		&cli.IntFlag{
+			Name:  "pre-dump-iterations",
+			Usage: "Number of pre-dump iterations to perform before the final checkpoint. Implies --pre-dump.",
+			Value: 1,
+		},
 	},
 
 	Action: func(c *cli.Context) error {
@@ -80,17 +136,56 @@ var checkpointCommand = cli.Command{
 			)
 		}
 
-		request := &experimental.CheckpointContainerRequest{
-			Options: &experimental.CheckpointContainerOptions{
-				CommonOptions: &experimental.CheckpointRestoreOptions{
-					Archive:        c.String("export"),
-					Keep:           c.Bool("keep"),
-					TcpEstablished: c.Bool("tcp-established"),
-					Compression:    int64(compression),
-				},
-				LeaveRunning: c.Bool("leave-running"),
+		options := &experimental.CheckpointContainerOptions{
+			CommonOptions: &experimental.CheckpointRestoreOptions{
+				Archive:        c.String("export"),
+				Keep:           c.Bool("keep"),
+				TcpEstablished: c.Bool("tcp-established"),
+				Compression:    int64(compression),
 			},
+			LeaveRunning: c.Bool("leave-running"),
+			Image:        c.String("image"),
+		}
+
+		preDumpIterations := c.Int("pre-dump-iterations")
+		if c.Bool("pre-dump") && preDumpIterations < 1 {
+			preDumpIterations = 1
 		}
+		if c.IsSet("pre-dump-iterations") || c.Bool("pre-dump") {
+			if c.Bool("pod") {
+				return errors.New("--pre-dump is not supported together with --pod")
+			}
+			preDumpRequest := &experimental.PreCheckpointContainerRequest{}
+			for i := 0; i < c.NArg(); i++ {
+				preDumpRequest.Id = c.Args().Get(i)
+				for iteration := 0; iteration < preDumpIterations; iteration++ {
+					preDumpRequest.Iteration = int64(iteration)
+					logrus.Debugf("PreCheckpointContainerRequest: %#v", preDumpRequest)
+					r, err := client.PreCheckpointContainer(context.Background(), preDumpRequest)
+					logrus.Debugf("PreCheckpointContainerResponse: %#v", r)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if c.Bool("pod") {
+			podRequest := &experimental.CheckpointPodSandboxRequest{Options: options}
+			for i := 0; i < c.NArg(); i++ {
+				podRequest.PodSandboxId = c.Args().Get(i)
+				logrus.Debugf("CheckpointPodSandboxRequest: %#v", podRequest)
+				r, err := client.CheckpointPodSandbox(context.Background(), podRequest)
+				logrus.Debugf("CheckpointPodSandboxResponse: %#v", r)
+				if err != nil {
+					return err
+				}
+				fmt.Println(podRequest.PodSandboxId)
+			}
+			return nil
+		}
+
+		request := &experimental.CheckpointContainerRequest{Options: options}
 		for i := 0; i < c.NArg(); i++ {
 			request.Id = c.Args().Get(i)
 			logrus.Debugf("CheckpointContainerRequest: %#v", request)
@@ -130,10 +225,14 @@ var restoreCommand = cli.Command{
 			Name:  "tcp-established",
 			Usage: "Restore a container with established TCP connections.",
 		},
+		&cli.StringFlag{
+			Name:  "image",
+			Usage: "Pull and restore the checkpoint from an OCI image NAME[:TAG] instead of --import.",
+		},
 	},
 
 	Action: func(c *cli.Context) error {
-		if c.NArg() == 0 && c.String("import") == "" {
+		if c.NArg() == 0 && c.String("import") == "" && c.String("image") == "" {
 			return cli.ShowSubcommandHelp(c)
 		}
 		address := c.String("connect")
@@ -145,20 +244,48 @@ var restoreCommand = cli.Command{
 		defer conn.Close()
 		client := experimental.NewRuntimeServiceClient(conn)
 
-		request := &experimental.RestoreContainerRequest{
-			Options: &experimental.RestoreContainerOptions{
-				PodSandboxId: func() string {
-					if c.IsSet("pod") {
-						return c.String("pod")
-					}
-					return ""
-				}(),
-				CommonOptions: &experimental.CheckpointRestoreOptions{
-					Archive:        c.String("import"),
-					Keep:           c.Bool("keep"),
-					TcpEstablished: c.Bool("tcp-established"),
-				},
+		restoreOptions := &experimental.RestoreContainerOptions{
+			PodSandboxId: func() string {
+				if c.IsSet("pod") {
+					return c.String("pod")
+				}
+				return ""
+			}(),
+			CommonOptions: &experimental.CheckpointRestoreOptions{
+				Archive:        c.String("import"),
+				Keep:           c.Bool("keep"),
+				TcpEstablished: c.Bool("tcp-established"),
 			},
+			Image: c.String("image"),
+		}
+
+		if archivePath := c.String("import"); archivePath != "" {
+			isPod, err := archiveIsPod(archivePath)
+			if err != nil {
+				return errors.Wrap(err, "failed to inspect checkpoint archive")
+			}
+			if isPod {
+				podRequest := &experimental.RestorePodSandboxRequest{
+					PodSandboxId: c.Args().Get(0),
+					Options:      restoreOptions,
+				}
+				logrus.Debugf("RestorePodSandboxRequest: %#v", podRequest)
+				r, err := client.RestorePodSandbox(context.Background(), podRequest)
+				logrus.Debugf("RestorePodSandboxResponse: %#v", r)
+				if err != nil {
+					return err
+				}
+				result, err := json.MarshalIndent(r, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s\n", result)
+				return nil
+			}
+		}
+
+		request := &experimental.RestoreContainerRequest{
+			Options: restoreOptions,
 		}
 
 		var ids []string
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestArchive writes an uncompressed tar to dir containing a single
+// empty entry named name, mirroring the layout Checkpoint produces.
+func writeTestArchive(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, "archive.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	defer tw.Close()
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Size: 0, Mode: 0o644}); err != nil {
+		t.Fatalf("failed to write tar header: %v", err)
+	}
+	return path
+}
+
+func TestArchiveIsPod(t *testing.T) {
+	dir := t.TempDir()
+
+	podArchive := writeTestArchive(t, dir, podDumpMarker)
+	isPod, err := archiveIsPod(podArchive)
+	if err != nil {
+		t.Fatalf("archiveIsPod returned error: %v", err)
+	}
+	if !isPod {
+		t.Errorf("expected a real pod checkpoint archive (containing %q) to be detected as a pod archive", podDumpMarker)
+	}
+}
+
+func TestArchiveIsPodSingleContainer(t *testing.T) {
+	dir := t.TempDir()
+
+	ctrArchive := writeTestArchive(t, dir, "config.json")
+	isPod, err := archiveIsPod(ctrArchive)
+	if err != nil {
+		t.Fatalf("archiveIsPod returned error: %v", err)
+	}
+	if isPod {
+		t.Errorf("expected a single-container checkpoint archive to not be detected as a pod archive")
+	}
+}
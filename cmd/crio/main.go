@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -17,6 +18,7 @@ import (
 	_ "github.com/containers/libpod/v2/pkg/hooks/0.1.0"
 	"github.com/containers/storage/pkg/reexec"
 	"github.com/cri-o/cri-o/internal/criocli"
+	"github.com/cri-o/cri-o/internal/lib/shutdown"
 	"github.com/cri-o/cri-o/internal/log"
 	"github.com/cri-o/cri-o/internal/signals"
 	"github.com/cri-o/cri-o/internal/version"
@@ -27,7 +29,6 @@ import (
 	serverV1alpha2 "github.com/cri-o/cri-o/v1alpha2/server"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/sirupsen/logrus"
-	"github.com/soheilhy/cmux"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/sys/unix"
 	"google.golang.org/grpc"
@@ -49,10 +50,12 @@ func writeCrioGoroutineStacks() {
 func catchShutdown(
 	ctx context.Context,
 	cancel context.CancelFunc,
+	cliCtx *cli.Context,
 	gserver *grpc.Server,
 	sserverV1 *serverV1.Server,
 	sserverV1alpha2 *serverV1alpha2.Server,
 	hserver *http.Server,
+	tlsMgr *tlsManager,
 	signalled *bool,
 ) {
 	sig := make(chan os.Signal, 2048)
@@ -71,6 +74,14 @@ func catchShutdown(
 				continue
 			case unix.SIGPIPE:
 				continue
+			case signals.Hup:
+				reloadConfig(cliCtx, sserverV1, sserverV1alpha2)
+				if tlsMgr != nil {
+					if err := tlsMgr.reload(); err != nil {
+						logrus.Errorf("failed to reload TCP listener TLS material: %v", err)
+					}
+				}
+				continue
 			case signals.Interrupt:
 				logrus.Debugf("Caught SIGINT")
 			case signals.Term:
@@ -78,8 +89,9 @@ func catchShutdown(
 			default:
 				continue
 			}
+			notifyStopping()
 			*signalled = true
-			gserver.GracefulStop()
+			gracefulStopWithTimeout(gserver, sserverV1, sserverV1alpha2, sserverV1.ContainerServer.Config().ShutdownTimeout)
 			hserver.Shutdown(ctx) // nolint: errcheck
 			if err := sserverV1.StopStreamServer(); err != nil {
 				logrus.Warnf("error shutting down streaming server: %v", err)
@@ -96,6 +108,13 @@ func catchShutdown(
 			if err := sserverV1alpha2.Shutdown(ctx); err != nil {
 				logrus.Warnf("error shutting down main service %v", err)
 			}
+			// Both services are down and every listener has stopped
+			// accepting, so this is as clean a stop as crio gets. Record
+			// it so the next startup can tell this apart from a crash
+			// and skip wiping containers that are still good.
+			if err := shutdown.WriteMarker(sserverV1.ContainerServer.Config().CleanShutdownFile); err != nil {
+				logrus.Warnf("error writing clean shutdown marker: %v", err)
+			}
 			return
 		}
 	}()
@@ -233,14 +252,26 @@ func main() {
 
 		grpcServer := grpc.NewServer(
 			grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
+				peerCommonNameUnaryInterceptor(),
 				metrics.UnaryInterceptor(),
 				log.UnaryInterceptor(),
 			)),
-			grpc.StreamInterceptor(log.StreamInterceptor()),
+			grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
+				peerCommonNameStreamInterceptor(),
+				log.StreamInterceptor(),
+			)),
 			grpc.MaxSendMsgSize(config.GRPCMaxSendMsgSize),
 			grpc.MaxRecvMsgSize(config.GRPCMaxRecvMsgSize),
 		)
 
+		var tlsMgr *tlsManager
+		if config.ListenTCP != "" {
+			tlsMgr, err = newTLSManager(config.TLSCert, config.TLSKey, config.TLSCA, config.ClientCAFile)
+			if err != nil {
+				logrus.Fatalf("failed to load TCP listener TLS material: %v", err)
+			}
+		}
+
 		serviceV1, err := serverV1.New(ctx, config)
 		if err != nil {
 			logrus.Fatal(err)
@@ -251,6 +282,25 @@ func main() {
 			logrus.Fatal(err)
 		}
 
+		runSubreaper(ctx, config.EnableSubreaper)
+
+		// hadCleanShutdown reflects how the *previous* run of crio ended:
+		// true means it got all the way through catchShutdown's graceful
+		// path, false means it crashed or was killed. Combined with
+		// tmpfsVersionFileExists (the reboot signal: absence means the
+		// node rebooted since VersionFile was last written) via
+		// shutdown.ShouldWipeContainers, it tells us whether to wipe
+		// stale container state now, so a crash wipes it even on a node
+		// that didn't reboot.
+		hadCleanShutdown, err := shutdown.HasMarker(config.CleanShutdownFile)
+		if err != nil {
+			logrus.Warnf("error checking clean shutdown marker: %v", err)
+		}
+		logrus.Infof("clean shutdown marker from previous run: %v", hadCleanShutdown)
+
+		_, statErr := os.Stat(config.VersionFile)
+		tmpfsVersionFileExists := statErr == nil
+
 		// Immediately upon start up, write our new version files
 		// we write one to a tmpfs, so we can detect when a node rebooted.
 		// in this sitaution, we want to wipe containers
@@ -263,14 +313,27 @@ func main() {
 			logrus.Fatal(err)
 		}
 
+		if shutdown.ShouldWipeContainers(tmpfsVersionFileExists, hadCleanShutdown) {
+			logrus.Infof("wiping stale container state left over from a crash or reboot")
+			for _, sb := range serviceV1.ContainerServer.ListSandboxes() {
+				if err := serviceV1.ContainerServer.RemoveSandbox(sb.ID()); err != nil {
+					logrus.Warnf("failed to wipe sandbox %s: %v", sb.ID(), err)
+				}
+			}
+		}
+
+		// Clear the marker now that this run has its own version files
+		// down, so a crash before the next clean shutdown is recorded
+		// correctly for the run after that.
+		if err := shutdown.RemoveMarker(config.CleanShutdownFile); err != nil {
+			logrus.Warnf("error removing clean shutdown marker: %v", err)
+		}
+
 		runtimeV1.RegisterRuntimeServiceServer(grpcServer, serviceV1)
 		runtimeV1.RegisterImageServiceServer(grpcServer, serviceV1)
 		runtimeV1alpha2.RegisterRuntimeServiceServer(grpcServer, serviceV1alpha2)
 		runtimeV1alpha2.RegisterImageServiceServer(grpcServer, serviceV1alpha2)
 
-		// after the daemon is done setting up we can notify systemd api
-		notifySystem()
-
 		go func() { serviceV1.StartExitMonitor() }()
 		go func() { serviceV1alpha2.StartExitMonitor() }()
 
@@ -298,10 +361,6 @@ func main() {
 			}
 		}
 
-		m := cmux.New(lis)
-		grpcL := m.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
-		httpL := m.Match(cmux.HTTP1Fast())
-
 		infoMux := serviceV1.GetInfoMux()
 		httpServer := &http.Server{
 			Handler:     infoMux,
@@ -309,30 +368,29 @@ func main() {
 		}
 
 		graceful := false
-		catchShutdown(ctx, cancel, grpcServer, serviceV1, serviceV1alpha2, httpServer, &graceful)
-
-		go func() {
-			if err := grpcServer.Serve(grpcL); err != nil {
-				logrus.Errorf("unable to run GRPC server: %v", err)
-			}
-		}()
-		go func() {
-			if err := httpServer.Serve(httpL); err != nil {
-				logrus.Debugf("closed http server")
-			}
-		}()
-
-		serverCloseCh := make(chan struct{})
-		go func() {
-			defer close(serverCloseCh)
-			if err := m.Serve(); err != nil {
-				if graceful && strings.Contains(strings.ToLower(err.Error()), "use of closed network connection") {
-					err = nil
-				} else {
-					logrus.Errorf("Failed to serve grpc request: %v", err)
-				}
+		catchShutdown(ctx, cancel, c, grpcServer, serviceV1, serviceV1alpha2, httpServer, tlsMgr, &graceful)
+
+		serverCloseCh := serveOn(lis, nil, grpcServer, httpServer, &graceful, "unix")
+
+		// A second, optional listener lets the kubelet reach this crio
+		// over TCP+mTLS instead of the unix socket, for setups (e.g.
+		// Kata/VM isolation) where the kubelet and crio don't share a
+		// filesystem. The info/metrics mux is reachable on it too, same
+		// as on the unix socket.
+		var tcpServerCloseCh chan struct{}
+		if config.ListenTCP != "" {
+			tcpLis, err := net.Listen("tcp", config.ListenTCP)
+			if err != nil {
+				logrus.Fatalf("failed to listen on %s: %v", config.ListenTCP, err)
 			}
-		}()
+			tcpServerCloseCh = serveOn(tcpLis, tlsMgr, grpcServer, httpServer, &graceful, "tcp")
+		}
+
+		// Both listeners are accepting by now (net.Listen/serverV1.Listen
+		// already bound and started listening before serveOn handed them
+		// to cmux), so it's safe to tell systemd we're ready.
+		notifyReady(fmt.Sprintf("runtime=%s listen=%s", config.DefaultRuntime, config.Listen))
+		go runWatchdog(ctx, serviceV1, config.WatchdogProbeTimeout, config.WatchdogFailureThreshold)
 
 		streamServerCloseChV1 := serviceV1.StreamingServerCloseChan()
 		serverMonitorsChV1 := serviceV1.MonitorsCloseChan()
@@ -344,6 +402,7 @@ func main() {
 		case <-streamServerCloseChV1alpha2:
 		case <-serverMonitorsChV1alpha2:
 		case <-serverCloseCh:
+		case <-tcpServerCloseCh:
 		}
 
 		if err := serviceV1.Shutdown(ctx); err != nil {
@@ -381,6 +440,11 @@ func main() {
 		<-serverCloseCh
 		logrus.Debug("closed main server")
 
+		if tcpServerCloseCh != nil {
+			<-tcpServerCloseCh
+			logrus.Debug("closed tcp server")
+		}
+
 		return nil
 	}
 
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/cri-o/cri-o/internal/process"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultSubreaperFallbackInterval is how often runSubreaper re-scans for
+// zombies via process.Reaper.Fallback, to catch a child that predates
+// Start's subreaper registration.
+const defaultSubreaperFallbackInterval = 30 * time.Second
+
+// runSubreaper opts crio into the Linux child-subreaper mechanism
+// (config's enable_subreaper) so exited children -- e.g. a sandbox's pinned
+// PID namespace placeholder process -- are reaped promptly instead of
+// lingering as zombies until their original parent gets around to it. It is
+// opt-in because taking over subreaper duties changes which process adopts
+// orphaned grandchildren system-wide, which not every deployment wants.
+func runSubreaper(ctx context.Context, enabled bool) {
+	if !enabled {
+		return
+	}
+
+	reaper := process.NewReaper()
+	if err := reaper.Start(); err != nil {
+		logrus.Warnf("failed to start subreaper: %v", err)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(defaultSubreaperFallbackInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reaper.Fallback()
+			}
+		}
+	}()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/cri-o/cri-o/internal/criocli"
+	serverV1 "github.com/cri-o/cri-o/v1/server"
+	serverV1alpha2 "github.com/cri-o/cri-o/v1alpha2/server"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// reloadConfig re-reads the config file and flags from c, then pushes
+// every field ContainerServer.ReloadConfig considers safe to change at
+// runtime into both the v1 and v1alpha2 services. It brackets the swap
+// with sd_notify RELOADING=1/READY=1 so systemd Type=notify-reload units
+// see the daemon as transiently unready rather than assuming it's still
+// serving the old config while the swap is in flight.
+func reloadConfig(c *cli.Context, sserverV1 *serverV1.Server, sserverV1alpha2 *serverV1alpha2.Server) {
+	logrus.Infof("Caught SIGHUP, reloading configuration")
+
+	if _, err := daemon.SdNotify(false, daemon.SdNotifyReloading); err != nil {
+		logrus.Warnf("error sending RELOADING=1 to systemd: %v", err)
+	}
+	defer func() {
+		if _, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+			logrus.Warnf("error sending READY=1 to systemd: %v", err)
+		}
+	}()
+
+	newConfig, err := criocli.GetAndMergeConfigFromContext(c)
+	if err != nil {
+		logrus.Errorf("failed to reload configuration: %v", err)
+		return
+	}
+
+	if err := sserverV1.ContainerServer.ReloadConfig(newConfig); err != nil {
+		logrus.Errorf("failed to reload v1 configuration: %v", err)
+	}
+	if err := sserverV1alpha2.ContainerServer.ReloadConfig(newConfig); err != nil {
+		logrus.Errorf("failed to reload v1alpha2 configuration: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	serverV1 "github.com/cri-o/cri-o/v1/server"
+	serverV1alpha2 "github.com/cri-o/cri-o/v1alpha2/server"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// gracefulStopWithTimeout bounds gserver.GracefulStop(), which otherwise
+// blocks forever on a long-lived unary call like ImagePull. It first tells
+// both services to start refusing new exec/attach/port-forward requests
+// with Unavailable, without touching sessions already in progress, then
+// waits up to timeout for GracefulStop to finish on its own. If it
+// doesn't, it cancels each service's graceful-shutdown context, so
+// handlers selecting on it abort cleanly instead of being cut off
+// mid-syscall, and force-closes every connection with gserver.Stop().
+// While waiting, it periodically re-extends systemd's stop timeout so
+// TimeoutStopSec doesn't SIGKILL crio out from under a drain that's
+// making progress.
+func gracefulStopWithTimeout(gserver *grpc.Server, sserverV1 *serverV1.Server, sserverV1alpha2 *serverV1alpha2.Server, timeout time.Duration) {
+	sserverV1.DrainStreamServer()
+	sserverV1alpha2.DrainStreamServer()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gserver.GracefulStop()
+	}()
+
+	extendEvery := timeout / 3
+	if extendEvery <= 0 {
+		extendEvery = time.Second
+	}
+	ticker := time.NewTicker(extendEvery)
+	defer ticker.Stop()
+	extendShutdownTimeout(timeout)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			extendShutdownTimeout(timeout)
+		case <-deadline.C:
+			logrus.Warnf("graceful stop did not finish within %s, forcing shutdown", timeout)
+			sserverV1.CancelGracefulShutdown()
+			sserverV1alpha2.CancelGracefulShutdown()
+			gserver.Stop()
+			<-done
+			return
+		}
+	}
+}
+
+// extendShutdownTimeout asks systemd for another d worth of stop timeout,
+// the mechanism Type=notify units use instead of a fixed TimeoutStopSec
+// when a service is known to still be shutting down cleanly.
+func extendShutdownTimeout(d time.Duration) {
+	if _, err := daemon.SdNotify(false, fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d", d.Microseconds())); err != nil {
+		logrus.Warnf("error extending systemd stop timeout: %v", err)
+	}
+}
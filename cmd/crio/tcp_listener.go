@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/soheilhy/cmux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// serveOn demuxes lis into a gRPC sub-listener and an HTTP sub-listener
+// with its own cmux, same as the original unix-only setup, then serves
+// grpcServer and httpServer on them. If tlsMgr is non-nil, lis is wrapped
+// in a TLS listener first, so the same grpcServer/httpServer pair can be
+// reused for both the plaintext unix socket and the TCP+mTLS listener.
+// The returned channel closes once m.Serve returns, mirroring the
+// original serverCloseCh.
+func serveOn(lis net.Listener, tlsMgr *tlsManager, grpcServer *grpc.Server, httpServer *http.Server, graceful *bool, label string) chan struct{} {
+	if tlsMgr != nil {
+		lis = tls.NewListener(lis, tlsMgr.config())
+	}
+
+	m := cmux.New(lis)
+	grpcL := m.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
+	httpL := m.Match(cmux.HTTP1Fast())
+
+	go func() {
+		if err := grpcServer.Serve(grpcL); err != nil {
+			logrus.Errorf("unable to run %s GRPC server: %v", label, err)
+		}
+	}()
+	go func() {
+		if err := httpServer.Serve(httpL); err != nil {
+			logrus.Debugf("closed %s http server", label)
+		}
+	}()
+
+	closeCh := make(chan struct{})
+	go func() {
+		defer close(closeCh)
+		if err := m.Serve(); err != nil {
+			if *graceful && strings.Contains(strings.ToLower(err.Error()), "use of closed network connection") {
+				err = nil
+			} else {
+				logrus.Errorf("failed to serve %s request: %v", label, err)
+			}
+		}
+	}()
+
+	return closeCh
+}
+
+// peerCommonNameKey is the context key under which the CN of a client
+// certificate presented over the TCP+mTLS listener is stored. The key type
+// is unexported in this package, so the log interceptors in internal/log
+// do not read it and their log lines do not include the CN.
+type peerCommonNameKey struct{}
+
+// peerCommonNameUnaryInterceptor stashes the CN of the client certificate
+// presented on an mTLS connection into the request context under
+// peerCommonNameKey, ahead of the existing log interceptor. Calls made
+// over the unix socket carry no CN.
+func peerCommonNameUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withPeerCommonName(ctx), req)
+	}
+}
+
+// peerCommonNameStreamInterceptor is peerCommonNameUnaryInterceptor's
+// streaming counterpart.
+func peerCommonNameStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = withPeerCommonName(ss.Context())
+		return handler(srv, wrapped)
+	}
+}
+
+func withPeerCommonName(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, peerCommonNameKey{}, tlsInfo.State.PeerCertificates[0].Subject.CommonName)
+}
+
+// tlsManager hot-reloads the TCP listener's server certificate and CA
+// pools. GetCertificate/GetConfigForClient read tlsManager's current
+// state on every new connection, so rebuilding that state under mu on
+// SIGHUP is enough for new connections to pick up rotated certs; already
+// established connections keep whatever they negotiated.
+type tlsManager struct {
+	mu sync.RWMutex
+
+	certFile, keyFile, caFile, clientCAFile string
+
+	cert         *tls.Certificate
+	clientCAPool *x509.CertPool
+	// requireClientCert is true whenever clientCAFile was actually
+	// configured, independent of whether caFile also contributed
+	// intermediates to clientCAPool, so an operator who only sets an
+	// intermediate CA bundle doesn't unexpectedly start requiring client
+	// certificates.
+	requireClientCert bool
+}
+
+// newTLSManager loads the initial certificate and CA pools from disk.
+func newTLSManager(certFile, keyFile, caFile, clientCAFile string) (*tlsManager, error) {
+	m := &tlsManager{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		caFile:       caFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := m.reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// reload rereads the certificate and CA files from disk and swaps them in
+// under mu. Callers should invoke it from the SIGHUP handler.
+func (m *tlsManager) reload() error {
+	cert, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "loading TLS certificate/key")
+	}
+
+	// TLSCA, when set, is an intermediate CA bundle trusted alongside
+	// ClientCAFile, for deployments where client certificates are issued
+	// by a sub-CA rather than directly by the CA in ClientCAFile.
+	var clientCAPool *x509.CertPool
+	if m.clientCAFile != "" {
+		clientCAPool, err = loadCertPool(m.clientCAFile)
+		if err != nil {
+			return errors.Wrap(err, "loading client CA")
+		}
+	}
+	if m.caFile != "" {
+		if clientCAPool == nil {
+			clientCAPool = x509.NewCertPool()
+		}
+		data, err := ioutil.ReadFile(m.caFile)
+		if err != nil {
+			return errors.Wrap(err, "loading intermediate CA")
+		}
+		if !clientCAPool.AppendCertsFromPEM(data) {
+			return errors.Errorf("no certificates found in %s", m.caFile)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cert = &cert
+	m.clientCAPool = clientCAPool
+	m.requireClientCert = m.clientCAFile != ""
+	return nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// config returns a *tls.Config whose GetConfigForClient closure defers to
+// tlsManager's current state on every handshake, so a reload() triggered
+// by SIGHUP takes effect for new connections without restarting the
+// listener. Client certificates are required whenever ClientCAFile was
+// configured; a TLSCA-only intermediate bundle with no ClientCAFile never
+// requires client certs on its own.
+func (m *tlsManager) config() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			m.mu.RLock()
+			defer m.mu.RUnlock()
+
+			cfg := &tls.Config{
+				GetCertificate: m.getCertificate,
+			}
+			if m.clientCAPool != nil {
+				cfg.ClientCAs = m.clientCAPool
+			}
+			if m.requireClientCert {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+func (m *tlsManager) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cert, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	serverV1 "github.com/cri-o/cri-o/v1/server"
+	"github.com/sirupsen/logrus"
+	runtimeV1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// defaultWatchdogProbeTimeout and defaultWatchdogFailureThreshold apply
+// when config leaves WatchdogProbeTimeout/WatchdogFailureThreshold unset.
+const (
+	defaultWatchdogProbeTimeout     = 5 * time.Second
+	defaultWatchdogFailureThreshold = 3
+)
+
+// notifyReady tells systemd the daemon is ready to serve, with a STATUS=
+// line summarizing the runtime it's about to serve requests with. Callers
+// should only call this once every listener is already accepting.
+func notifyReady(status string) {
+	if _, err := daemon.SdNotify(false, "READY=1\nSTATUS="+status); err != nil {
+		logrus.Warnf("error sending READY=1 to systemd: %v", err)
+	}
+}
+
+// notifyStopping tells systemd the daemon is tearing down, so
+// Type=notify units stop treating it as ready the moment shutdown begins
+// instead of only once the process actually exits.
+func notifyStopping() {
+	if _, err := daemon.SdNotify(false, daemon.SdNotifyStopping); err != nil {
+		logrus.Warnf("error sending STOPPING=1 to systemd: %v", err)
+	}
+}
+
+// runWatchdog pings systemd's watchdog at half of WATCHDOG_USEC, the
+// interval sd_watchdog_enabled's contract requires, but only while
+// serviceV1 answers a RuntimeService.Version call within probeTimeout. It
+// returns (stopping the pings for good) after failureThreshold
+// consecutive probe failures, so systemd's own watchdog timeout restarts
+// a wedged daemon instead of crio pretending it's still alive. It is a
+// no-op if the process wasn't started under a systemd watchdog unit.
+func runWatchdog(ctx context.Context, serviceV1 *serverV1.Server, probeTimeout time.Duration, failureThreshold int) {
+	usec, enabled, err := sdWatchdogEnabled()
+	if err != nil {
+		logrus.Warnf("failed to parse systemd watchdog environment: %v", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	if probeTimeout <= 0 {
+		probeTimeout = defaultWatchdogProbeTimeout
+	}
+	if failureThreshold <= 0 {
+		failureThreshold = defaultWatchdogFailureThreshold
+	}
+
+	interval := time.Duration(usec/2) * time.Microsecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			_, err := serviceV1.Version(probeCtx, &runtimeV1.VersionRequest{})
+			cancel()
+			if err != nil {
+				failures++
+				logrus.Warnf("watchdog liveness probe failed (%d/%d): %v", failures, failureThreshold, err)
+				if failures >= failureThreshold {
+					logrus.Errorf("watchdog liveness probe failed %d times in a row, no longer pinging systemd", failures)
+					return
+				}
+				continue
+			}
+
+			failures = 0
+			if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+				logrus.Warnf("error sending WATCHDOG=1 to systemd: %v", err)
+			}
+		}
+	}
+}
+
+// sdWatchdogEnabled mirrors sd_watchdog_enabled(3): the watchdog is
+// enabled when WATCHDOG_USEC is set to a positive value and, if
+// WATCHDOG_PID is also set, it names this process.
+func sdWatchdogEnabled() (usec uint64, enabled bool, err error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, false, nil
+	}
+	usec, err = strconv.ParseUint(usecStr, 10, 64)
+	if err != nil || usec == 0 {
+		return 0, false, err
+	}
+
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, false, err
+		}
+		if pid != os.Getpid() {
+			return 0, false, nil
+		}
+	}
+
+	return usec, true, nil
+}
@@ -0,0 +1,445 @@
+package lib
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cri-o/cri-o/internal/lib/sandbox"
+	"github.com/cri-o/cri-o/internal/oci"
+	"github.com/opencontainers/selinux/go-selinux/label"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+	pb "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+	"k8s.io/kubernetes/pkg/kubelet/dockershim/network/hostport"
+)
+
+var (
+	sandboxesBucket     = []byte("sandboxes")
+	sandboxNamesBucket  = []byte("sandbox-names")
+	containersBucket    = []byte("containers")
+	podContainersBucket = []byte("pod-containers")
+)
+
+// sandboxRecord is the durable, JSON-marshalable snapshot of a
+// sandbox.Sandbox that BoltState keeps in sandboxesBucket. Its fields
+// mirror sandbox.New's positional arguments, so Rehydrate can reconstruct a
+// live *sandbox.Sandbox directly from the database instead of LoadSandbox
+// re-parsing the sandbox's on-disk config.json.
+type sandboxRecord struct {
+	ID              string
+	Namespace       string
+	Name            string
+	KubeName        string
+	LogDir          string
+	Labels          map[string]string
+	KubeAnnotations map[string]string
+	ProcessLabel    string
+	MountLabel      string
+	Metadata        *pb.PodSandboxMetadata
+	ShmPath         string
+	CgroupParent    string
+	Privileged      bool
+	RuntimeHandler  string
+	ResolvPath      string
+	Hostname        string
+	PortMappings    []*hostport.PortMapping
+	HostNetwork     bool
+	// LockSlot is the numeric slot lock.Manager had allocated to this
+	// sandbox's ID, if any. Rehydrate sets it back on the sandbox, so
+	// ContainerServer can hand the ID the same slot via
+	// lock.Manager.RestoreSlot instead of leaking the slot the previous
+	// process held forever.
+	LockSlot *uint32
+}
+
+// containerRecord is the durable counterpart for oci.Container, mirroring
+// oci.NewContainer's positional arguments. IsInfra distinguishes the
+// records Rehydrate must hand back to AddInfraContainer rather than
+// AddContainer.
+type containerRecord struct {
+	ID              string
+	Name            string
+	BundlePath      string
+	LogPath         string
+	Labels          map[string]string
+	Annotations     map[string]string
+	KubeAnnotations map[string]string
+	Image           string
+	ImageName       string
+	ImageRef        string
+	Metadata        *oci.Metadata
+	SandboxID       string
+	Terminal        bool
+	Stdin           bool
+	StdinOnce       bool
+	RuntimeHandler  string
+	Dir             string
+	Created         time.Time
+	StopSignal      string
+	IsInfra         bool
+}
+
+// BoltState is a State backed by a single bbolt database under the storage
+// root. It embeds an InMemoryState to serve Get/List within the running
+// process exactly as before; bbolt is only consulted on Add/Remove/Rename, and
+// once at startup by Rehydrate, which is what lets a restart rebuild
+// ContainerServer in O(N) reads instead of re-parsing every container's
+// config.json and annotations the way LoadSandbox/LoadContainer do.
+type BoltState struct {
+	*InMemoryState
+	db *bolt.DB
+}
+
+// NewBoltState opens (creating if necessary) a BoltState-backed database at path.
+func NewBoltState(path string) (*BoltState, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, errors.Wrap(err, "failed to create state database directory")
+	}
+
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open state database %s", path)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{sandboxesBucket, sandboxNamesBucket, containersBucket, podContainersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() // nolint:errcheck
+		return nil, errors.Wrap(err, "failed to initialize state database buckets")
+	}
+
+	return &BoltState{
+		InMemoryState: NewInMemoryState(),
+		db:            db,
+	}, nil
+}
+
+// AddSandbox persists sb and adds it to the live in-memory cache.
+func (b *BoltState) AddSandbox(sb *sandbox.Sandbox) error {
+	record := sandboxRecord{
+		ID:              sb.ID(),
+		Namespace:       sb.Namespace(),
+		Name:            sb.Name(),
+		KubeName:        sb.KubeName(),
+		LogDir:          sb.LogDir(),
+		Labels:          sb.Labels(),
+		KubeAnnotations: sb.Annotations(),
+		ProcessLabel:    sb.ProcessLabel(),
+		MountLabel:      sb.MountLabel(),
+		Metadata:        sb.Metadata(),
+		ShmPath:         sb.ShmPath(),
+		CgroupParent:    sb.CgroupParent(),
+		Privileged:      sb.Privileged(),
+		RuntimeHandler:  sb.RuntimeHandler(),
+		ResolvPath:      sb.ResolvPath(),
+		Hostname:        sb.Hostname(),
+		PortMappings:    sb.PortMappings(),
+		HostNetwork:     sb.HostNetwork(),
+	}
+	if slot, ok := sb.LockSlot(); ok {
+		record.LockSlot = &slot
+	}
+
+	data, err := json.Marshal(&record)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal sandbox record")
+	}
+
+	if err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(sandboxesBucket).Put([]byte(record.ID), data); err != nil {
+			return err
+		}
+		return tx.Bucket(sandboxNamesBucket).Put([]byte(record.Name), []byte(record.ID))
+	}); err != nil {
+		return errors.Wrap(err, "failed to persist sandbox")
+	}
+
+	return b.InMemoryState.AddSandbox(sb)
+}
+
+// RemoveSandbox removes sb's persisted record, its sandbox-names index
+// entry, and its in-memory entry.
+func (b *BoltState) RemoveSandbox(id string) error {
+	if err := b.db.Update(func(tx *bolt.Tx) error {
+		sandboxes := tx.Bucket(sandboxesBucket)
+		data := sandboxes.Get([]byte(id))
+		if data != nil {
+			var record sandboxRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return errors.Wrap(err, "failed to unmarshal sandbox record")
+			}
+			if err := tx.Bucket(sandboxNamesBucket).Delete([]byte(record.Name)); err != nil {
+				return err
+			}
+		}
+		return sandboxes.Delete([]byte(id))
+	}); err != nil {
+		return errors.Wrap(err, "failed to remove persisted sandbox")
+	}
+	return b.InMemoryState.RemoveSandbox(id)
+}
+
+// RenameSandbox updates the persisted sandbox record's Name to newName and
+// repoints the sandbox-names index at it, so the new name survives
+// Rehydrate after a restart instead of the persisted record reverting a
+// rename the in-memory *sandbox.Sandbox already has.
+func (b *BoltState) RenameSandbox(id, newName string) error {
+	if err := b.db.Update(func(tx *bolt.Tx) error {
+		sandboxes := tx.Bucket(sandboxesBucket)
+		data := sandboxes.Get([]byte(id))
+		if data == nil {
+			return errors.Errorf("no persisted sandbox record for %s", id)
+		}
+		var record sandboxRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return errors.Wrap(err, "failed to unmarshal sandbox record")
+		}
+
+		names := tx.Bucket(sandboxNamesBucket)
+		if err := names.Delete([]byte(record.Name)); err != nil {
+			return err
+		}
+		record.Name = newName
+		if err := names.Put([]byte(record.Name), []byte(record.ID)); err != nil {
+			return err
+		}
+
+		newData, err := json.Marshal(&record)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal sandbox record")
+		}
+		return sandboxes.Put([]byte(record.ID), newData)
+	}); err != nil {
+		return errors.Wrap(err, "failed to rename persisted sandbox")
+	}
+	return b.InMemoryState.RenameSandbox(id, newName)
+}
+
+// AddContainer persists ctr under its sandbox's pod-containers index and
+// adds it to the live in-memory cache.
+func (b *BoltState) AddContainer(ctr *oci.Container) error {
+	if err := b.persistContainer(ctr, false); err != nil {
+		return err
+	}
+	return b.InMemoryState.AddContainer(ctr)
+}
+
+// AddInfraContainer persists ctr, marked as an infra container, and adds it
+// to the live in-memory cache.
+func (b *BoltState) AddInfraContainer(ctr *oci.Container) error {
+	if err := b.persistContainer(ctr, true); err != nil {
+		return err
+	}
+	return b.InMemoryState.AddInfraContainer(ctr)
+}
+
+func (b *BoltState) persistContainer(ctr *oci.Container, isInfra bool) error {
+	record := containerRecord{
+		ID:              ctr.ID(),
+		Name:            ctr.Name(),
+		BundlePath:      ctr.BundlePath(),
+		LogPath:         ctr.LogPath(),
+		Labels:          ctr.Labels(),
+		Annotations:     ctr.Annotations(),
+		KubeAnnotations: ctr.KubeAnnotations(),
+		Image:           ctr.Image(),
+		ImageName:       ctr.ImageName(),
+		ImageRef:        ctr.ImageRef(),
+		Metadata:        ctr.Metadata(),
+		SandboxID:       ctr.Sandbox(),
+		Terminal:        ctr.Terminal(),
+		Stdin:           ctr.Stdin(),
+		StdinOnce:       ctr.StdinOnce(),
+		RuntimeHandler:  ctr.RuntimeHandler(),
+		Dir:             ctr.Dir(),
+		Created:         ctr.CreatedAt(),
+		StopSignal:      ctr.StopSignal(),
+		IsInfra:         isInfra,
+	}
+
+	data, err := json.Marshal(&record)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal container record")
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(containersBucket).Put([]byte(record.ID), data); err != nil {
+			return err
+		}
+		podBucket, err := tx.Bucket(podContainersBucket).CreateBucketIfNotExists([]byte(record.SandboxID))
+		if err != nil {
+			return err
+		}
+		return podBucket.Put([]byte(record.ID), nil)
+	})
+}
+
+// RemoveContainer removes ctr's persisted record, its pod-containers
+// index entry, and its in-memory entry.
+func (b *BoltState) RemoveContainer(ctr *oci.Container) error {
+	if err := b.removePersistedContainer(ctr); err != nil {
+		return err
+	}
+	return b.InMemoryState.RemoveContainer(ctr)
+}
+
+// RemoveInfraContainer removes ctr's persisted record, its pod-containers
+// index entry, and its in-memory entry.
+func (b *BoltState) RemoveInfraContainer(ctr *oci.Container) error {
+	if err := b.removePersistedContainer(ctr); err != nil {
+		return err
+	}
+	return b.InMemoryState.RemoveInfraContainer(ctr)
+}
+
+func (b *BoltState) removePersistedContainer(ctr *oci.Container) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(containersBucket).Delete([]byte(ctr.ID())); err != nil {
+			return err
+		}
+		if podBucket := tx.Bucket(podContainersBucket).Bucket([]byte(ctr.Sandbox())); podBucket != nil {
+			return podBucket.Delete([]byte(ctr.ID()))
+		}
+		return nil
+	})
+}
+
+// RenameContainer updates the persisted container record's Name to newName,
+// so it survives Rehydrate after a restart instead of reverting a rename
+// the in-memory *oci.Container already has. Containers have no separate
+// name index bucket to repoint, unlike sandboxes.
+func (b *BoltState) RenameContainer(id, newName string) error {
+	if err := b.db.Update(func(tx *bolt.Tx) error {
+		containers := tx.Bucket(containersBucket)
+		data := containers.Get([]byte(id))
+		if data == nil {
+			return errors.Errorf("no persisted container record for %s", id)
+		}
+		var record containerRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return errors.Wrap(err, "failed to unmarshal container record")
+		}
+		record.Name = newName
+
+		newData, err := json.Marshal(&record)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal container record")
+		}
+		return containers.Put([]byte(record.ID), newData)
+	}); err != nil {
+		return errors.Wrap(err, "failed to rename persisted container")
+	}
+	return b.InMemoryState.RenameContainer(id, newName)
+}
+
+// Rehydrate reconstructs every sandbox and container BoltState has a
+// persisted record for and adds each one back into the live in-memory
+// cache, so ContainerServer can come back up after a restart in O(N) Bolt
+// reads. It also wires each sandbox's object graph back together -- an
+// infra container's SetInfraContainer, its sandbox's reserved SELinux
+// label, and SetCreated -- since those need nothing beyond what's already
+// in the records. What it can't do without c.store/c.runtime (each
+// container's on-disk OCI spec and current runtime status, and so whether
+// its sandbox should come back marked stopped) is left for
+// ContainerServer.hydrateRehydratedState to finish right after a
+// successful Rehydrate. LoadSandbox/LoadContainer remain the fallback for
+// any ID found on disk afterward that has no record here, e.g. on the
+// first restart after upgrading from InMemoryState.
+func (b *BoltState) Rehydrate() error {
+	var sandboxRecords []sandboxRecord
+	var containerRecords []containerRecord
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(sandboxesBucket).ForEach(func(_, v []byte) error {
+			var r sandboxRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			sandboxRecords = append(sandboxRecords, r)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return tx.Bucket(containersBucket).ForEach(func(_, v []byte) error {
+			var r containerRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			containerRecords = append(containerRecords, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to read state database")
+	}
+
+	sandboxesByID := make(map[string]*sandbox.Sandbox, len(sandboxRecords))
+	for _, r := range sandboxRecords {
+		sb, err := sandbox.New(r.ID, r.Namespace, r.Name, r.KubeName, r.LogDir, r.Labels, r.KubeAnnotations,
+			r.ProcessLabel, r.MountLabel, r.Metadata, r.ShmPath, r.CgroupParent, r.Privileged, r.RuntimeHandler,
+			r.ResolvPath, r.Hostname, r.PortMappings, r.HostNetwork)
+		if err != nil {
+			logrus.Warnf("failed to rehydrate sandbox %s from state database: %v", r.ID, err)
+			continue
+		}
+		if r.LockSlot != nil {
+			sb.SetLockSlot(*r.LockSlot)
+		}
+		sandboxesByID[r.ID] = sb
+	}
+
+	for _, r := range containerRecords {
+		ctr, err := oci.NewContainer(r.ID, r.Name, r.BundlePath, r.LogPath, r.Labels, r.Annotations, r.KubeAnnotations,
+			r.Image, r.ImageName, r.ImageRef, r.Metadata, r.SandboxID, r.Terminal, r.Stdin, r.StdinOnce,
+			r.RuntimeHandler, r.Dir, r.Created, r.StopSignal)
+		if err != nil {
+			logrus.Warnf("failed to rehydrate container %s from state database: %v", r.ID, err)
+			continue
+		}
+		if !r.IsInfra {
+			if err := b.InMemoryState.AddContainer(ctr); err != nil {
+				logrus.Warnf("failed to add rehydrated container %s: %v", r.ID, err)
+			}
+			continue
+		}
+
+		sb, ok := sandboxesByID[r.SandboxID]
+		if !ok {
+			logrus.Warnf("rehydrated infra container %s has no matching sandbox %s, skipping", r.ID, r.SandboxID)
+			continue
+		}
+		if err := label.ReserveLabel(sb.ProcessLabel()); err != nil {
+			logrus.Warnf("failed to reserve SELinux label for rehydrated sandbox %s: %v", sb.ID(), err)
+		}
+		if err := sb.SetInfraContainer(ctr); err != nil {
+			logrus.Warnf("failed to wire infra container for rehydrated sandbox %s: %v", sb.ID(), err)
+			continue
+		}
+		if err := b.InMemoryState.AddInfraContainer(ctr); err != nil {
+			logrus.Warnf("failed to add rehydrated infra container %s: %v", r.ID, err)
+		}
+	}
+
+	for _, sb := range sandboxesByID {
+		sb.SetCreated()
+		if err := b.InMemoryState.AddSandbox(sb); err != nil {
+			logrus.Warnf("failed to add rehydrated sandbox %s: %v", sb.ID(), err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the underlying database.
+func (b *BoltState) Close() error {
+	return b.db.Close()
+}
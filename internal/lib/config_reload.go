@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/containers/libpod/pkg/hooks"
+	libconfig "github.com/cri-o/cri-o/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadableConfigFields lists the Config fields ReloadConfig is willing to
+// apply from a SIGHUP-triggered reread of the config file and flags.
+// Everything else is a startup-only setting: a difference there is
+// rejected rather than applied, so a SIGHUP can't silently change
+// something (like the storage driver or listen socket) that needs a
+// restart to take effect safely.
+var reloadableConfigFields = map[string]bool{
+	"LogLevel":            true,
+	"LogFormat":           true,
+	"LogFilePath":         true,
+	"LogFilter":           true,
+	"PauseImage":          true,
+	"PauseImageAuthFile":  true,
+	"PinnsPath":           true,
+	"Registries":          true,
+	"InsecureRegistries":  true,
+	"DefaultRuntime":      true,
+	"HooksDir":            true,
+	"RuntimeVMConfigPath": true,
+	"NetworkDir":          true,
+	"PluginDirs":          true,
+}
+
+// ReloadConfig diffs newConfig against the config ContainerServer is
+// currently running, rejects any change outside reloadableConfigFields,
+// validates the result, and only then swaps it in under configMu. Rejected
+// fields are not returned as an error: they are logged, listing every one
+// of them so the admin knows exactly what still needs a restart, and keep
+// their running values while the reloadable fields are still applied.
+func (c *ContainerServer) ReloadConfig(newConfig *libconfig.Config) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	running := c.config
+
+	if rejected := diffNonReloadable(running, newConfig); len(rejected) > 0 {
+		logrus.Errorf("ignoring changes to fields that cannot be reloaded without a restart: %v", rejected)
+	}
+
+	staged := *running
+	hooksDirChanged := !reflect.DeepEqual(running.HooksDir, newConfig.HooksDir)
+
+	staged.LogLevel = newConfig.LogLevel
+	staged.LogFormat = newConfig.LogFormat
+	staged.LogFilePath = newConfig.LogFilePath
+	staged.LogFilter = newConfig.LogFilter
+	staged.PauseImage = newConfig.PauseImage
+	staged.PauseImageAuthFile = newConfig.PauseImageAuthFile
+	staged.PinnsPath = newConfig.PinnsPath
+	staged.Registries = newConfig.Registries
+	staged.InsecureRegistries = newConfig.InsecureRegistries
+	staged.DefaultRuntime = newConfig.DefaultRuntime
+	staged.HooksDir = newConfig.HooksDir
+	staged.RuntimeVMConfigPath = newConfig.RuntimeVMConfigPath
+	staged.NetworkDir = newConfig.NetworkDir
+	staged.PluginDirs = newConfig.PluginDirs
+
+	if err := staged.Validate(true); err != nil {
+		return errors.Wrap(err, "validating reloaded configuration")
+	}
+
+	level, err := logrus.ParseLevel(staged.LogLevel)
+	if err != nil {
+		return errors.Wrap(err, "parsing reloaded log level")
+	}
+
+	c.config = &staged
+	logrus.SetLevel(level)
+
+	switch staged.LogFormat {
+	case "text":
+		// retain logrus's default.
+	case "json":
+		logrus.SetFormatter(new(logrus.JSONFormatter))
+	default:
+		return fmt.Errorf("unknown log-format %q", staged.LogFormat)
+	}
+
+	if staged.LogFilePath != "" {
+		f, err := os.OpenFile(staged.LogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0o666)
+		if err != nil {
+			return errors.Wrap(err, "opening reloaded log file")
+		}
+		logrus.SetOutput(f)
+	}
+
+	if hooksDirChanged {
+		newHooks, err := hooks.New(c.ctx, staged.HooksDir, []string{})
+		if err != nil {
+			return errors.Wrap(err, "rebuilding hook manager for reloaded HooksDir")
+		}
+		c.Hooks = newHooks
+		go c.Hooks.Monitor(c.ctx, make(chan error, 1))
+	}
+
+	return nil
+}
+
+// diffNonReloadable walks old and new field by field (recursing into
+// nested structs) and returns the dotted names of every exported field
+// outside reloadableConfigFields whose value differs between the two.
+func diffNonReloadable(old, new *libconfig.Config) []string {
+	var rejected []string
+	diffStructFields(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &rejected)
+	return rejected
+}
+
+func diffStructFields(oldV, newV reflect.Value, prefix string, rejected *[]string) {
+	t := oldV.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		oldField := oldV.Field(i)
+		newField := newV.Field(i)
+		if oldField.Kind() == reflect.Struct {
+			diffStructFields(oldField, newField, prefix+field.Name+".", rejected)
+			continue
+		}
+		if reloadableConfigFields[field.Name] {
+			continue
+		}
+		if !oldField.CanInterface() || !newField.CanInterface() {
+			continue
+		}
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			*rejected = append(*rejected, prefix+field.Name)
+		}
+	}
+}
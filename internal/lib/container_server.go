@@ -14,6 +14,8 @@ import (
 	cstorage "github.com/containers/storage"
 	"github.com/containers/storage/pkg/ioutils"
 	"github.com/containers/storage/pkg/truncindex"
+	"github.com/cri-o/cri-o/internal/lib/events"
+	"github.com/cri-o/cri-o/internal/lib/lock"
 	"github.com/cri-o/cri-o/internal/lib/sandbox"
 	"github.com/cri-o/cri-o/internal/oci"
 	"github.com/cri-o/cri-o/internal/storage"
@@ -43,9 +45,44 @@ type ContainerServer struct {
 	podIDIndex           *truncindex.TruncIndex
 	Hooks                *hooks.Manager
 
-	stateLock sync.Locker
-	state     *containerServerState
-	config    *libconfig.Config
+	// locks replaces the old global stateLock: it hands out a per-ID
+	// fcntl-backed Locker so AddSandbox/RemoveSandbox/StopContainerAndWait
+	// for unrelated sandboxes and containers no longer serialize against
+	// each other, and because the backing file lives under the run root,
+	// a second crio process racing the same ID still blocks correctly.
+	locks *lock.Manager
+	state State
+
+	// configMu guards config: ReloadConfig swaps it out from under a
+	// SIGHUP handler while every other method keeps reading it through
+	// Config(), so a reload can't race a request that's mid-flight.
+	configMu sync.RWMutex
+	config   *libconfig.Config
+
+	// events records every sandbox/container lifecycle transition, so
+	// operators can plug crio into an audit pipeline and test harnesses
+	// can assert against event traces instead of polling ListContainers.
+	events events.Journal
+
+	// ctx is the server's root context, kept around so ReloadConfig can
+	// restart the hook directory monitor with the same lifetime as the
+	// one New started.
+	ctx context.Context
+}
+
+// Events returns the Journal ContainerServer emits lifecycle events to.
+func (c *ContainerServer) Events() events.Journal {
+	return c.events
+}
+
+// emitEvent records ev, stamping its Time, and logs (rather than fails) if
+// the Journal can't be written to: a lost audit event shouldn't take down
+// the sandbox/container operation that produced it.
+func (c *ContainerServer) emitEvent(ev events.Event) {
+	ev.Time = time.Now()
+	if err := c.events.Write(ev); err != nil {
+		logrus.Warnf("failed to write %s %s event for %s: %v", ev.Type, ev.Status, ev.ID, err)
+	}
 }
 
 // Runtime returns the oci runtime for the ContainerServer
@@ -85,6 +122,8 @@ func (c *ContainerServer) PodIDIndex() *truncindex.TruncIndex {
 
 // Config gets the configuration for the ContainerServer
 func (c *ContainerServer) Config() *libconfig.Config {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
 	return c.config
 }
 
@@ -122,7 +161,17 @@ func New(ctx context.Context, configIface libconfig.Iface) (*ContainerServer, er
 		return nil, err
 	}
 
-	return &ContainerServer{
+	state, err := newState(config)
+	if err != nil {
+		return nil, err
+	}
+
+	journal, err := events.NewJournal(config.EventsLogger, filepath.Join(config.LogDir, "crio"))
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &ContainerServer{
 		runtime:              runtime,
 		store:                store,
 		storageImageServer:   imageService,
@@ -132,18 +181,177 @@ func New(ctx context.Context, configIface libconfig.Iface) (*ContainerServer, er
 		podNameIndex:         registrar.NewRegistrar(),
 		podIDIndex:           truncindex.NewTruncIndex([]string{}),
 		Hooks:                newHooks,
-		stateLock:            &sync.Mutex{},
-		state: &containerServerState{
-			containers:      oci.NewMemoryStore(),
-			infraContainers: oci.NewMemoryStore(),
-			sandboxes:       sandbox.NewMemoryStore(),
-			processLevels:   make(map[string]int),
-		},
-		config: config,
-	}, nil
-}
-
-// LoadSandbox loads a sandbox from the disk into the sandbox store
+		state:                state,
+		config:               config,
+		events:               journal,
+		ctx:                  ctx,
+	}
+
+	if boltState, ok := state.(*BoltState); ok {
+		if err := boltState.Rehydrate(); err != nil {
+			logrus.Warnf("failed to rehydrate state database: %v", err)
+		} else {
+			cs.hydrateRehydratedState()
+		}
+	}
+
+	locks, err := newLockManager(config)
+	if err != nil {
+		return nil, err
+	}
+	restoreLockSlots(locks, state)
+	cs.locks = locks
+
+	return cs, nil
+}
+
+// newLockManager opens the per-sandbox/container lock file under the run
+// root. Unlike the storage root, the run root is expected to be tmpfs-backed
+// and cleared on reboot, which is fine: the slots a fresh boot sees as free
+// are exactly the ones whose owning sandboxes are also gone.
+func newLockManager(config *libconfig.Config) (*lock.Manager, error) {
+	lockPath := filepath.Join(config.RunRoot, "crio", "locks")
+	return lock.NewManager(lockPath, config.NumLocks)
+}
+
+// restoreLockSlots re-registers the lock slot of every sandbox State just
+// rehydrated, so an ID keeps the same slot it had before the restart
+// instead of colliding with whatever crictl or the kubelet races against it
+// next. It is a best-effort step: a sandbox with no persisted slot (e.g.
+// InMemoryState, which never had one to save) simply gets a fresh one the
+// next time AddSandbox touches it.
+func restoreLockSlots(locks *lock.Manager, state State) {
+	for _, sb := range state.ListSandboxes() {
+		slot, ok := sb.LockSlot()
+		if !ok {
+			continue
+		}
+		if err := locks.RestoreSlot(sb.ID(), slot); err != nil {
+			logrus.Warnf("failed to restore lock slot for sandbox %s: %v", sb.ID(), err)
+		}
+	}
+}
+
+// newState picks the State backend ContainerServer should use: a BoltState
+// persisted under the storage root, falling back to a purely in-memory
+// state if the database can't be opened (e.g. a read-only storage root in
+// a test environment).
+func newState(config *libconfig.Config) (State, error) {
+	dbPath := filepath.Join(config.Root, "crio.db")
+	boltState, err := NewBoltState(dbPath)
+	if err != nil {
+		logrus.Warnf("could not open state database %s, falling back to in-memory state: %v", dbPath, err)
+		return NewInMemoryState(), nil
+	}
+	return boltState, nil
+}
+
+// applyInfraContainerSpec wires an infra container's on-disk OCI spec,
+// mount point, and any declared volumes onto ctr from m. m is the sandbox's
+// own parsed config.json rather than a spec filed under the infra
+// container's own directory: cri-o writes an infra container's spec
+// alongside its sandbox's. LoadSandbox and a rehydrated sandbox's disk
+// hydration pass both finish an infra container identically from here.
+func applyInfraContainerSpec(ctr *oci.Container, m *rspec.Spec) error {
+	ctr.SetSpec(m)
+	ctr.SetMountPoint(m.Annotations[annotations.MountPoint])
+
+	if m.Annotations[annotations.Volumes] == "" {
+		return nil
+	}
+	containerVolumes := []oci.ContainerVolume{}
+	if err := json.Unmarshal([]byte(m.Annotations[annotations.Volumes]), &containerVolumes); err != nil {
+		return fmt.Errorf("failed to unmarshal container volumes: %v", err)
+	}
+	for _, cv := range containerVolumes {
+		ctr.AddVolume(cv)
+	}
+	return nil
+}
+
+// applyContainerSpec wires a regular (non-infra) container's on-disk OCI
+// spec and mount point onto ctr from m, its own parsed config.json.
+// LoadContainer and a rehydrated container's disk hydration pass both
+// finish a container identically from here.
+func applyContainerSpec(ctr *oci.Container, m *rspec.Spec) {
+	ctr.SetSpec(m)
+	ctr.SetMountPoint(m.Annotations[annotations.MountPoint])
+}
+
+// refreshContainerStateFromDisk reads ctr's current state from disk via the
+// runtime and writes it straight back, in case crio died before it had a
+// chance to persist an exit code it had already observed.
+func (c *ContainerServer) refreshContainerStateFromDisk(ctr *oci.Container) error {
+	if err := c.ContainerStateFromDisk(ctr); err != nil {
+		return fmt.Errorf("error reading container state from disk %q: %v", ctr.ID(), err)
+	}
+	if err := c.ContainerStateToDisk(ctr); err != nil {
+		return fmt.Errorf("failed to write container state to disk %q: %v", ctr.ID(), err)
+	}
+	return nil
+}
+
+// hydrateRehydratedState finishes wiring every sandbox and container
+// State.Rehydrate just restored from the state database. Rehydrate itself
+// only has the persisted record to work from, so it stops at reconstructing
+// the object graph (including, for an infra container, SetInfraContainer
+// and reserving its SELinux label); it has no access to c.store or
+// c.runtime to do the rest. This parses each one's on-disk OCI spec and
+// mount point and refreshes its runtime status, then -- now that an infra
+// container's state reflects reality -- marks its sandbox stopped if that's
+// what the disk says, the same way LoadSandbox/LoadContainer do for an ID
+// Rehydrate didn't already restore.
+func (c *ContainerServer) hydrateRehydratedState() {
+	for _, sb := range c.ListSandboxes() {
+		infra := sb.InfraContainer()
+		if infra == nil {
+			logrus.Warnf("rehydrated sandbox %s has no infra container, skipping disk hydration", sb.ID())
+			continue
+		}
+		config, err := c.store.FromContainerDirectory(sb.ID(), "config.json")
+		if err != nil {
+			logrus.Warnf("failed to read config.json for rehydrated sandbox %s: %v", sb.ID(), err)
+			continue
+		}
+		var m rspec.Spec
+		if err := json.Unmarshal(config, &m); err != nil {
+			logrus.Warnf("failed to unmarshal config.json for rehydrated sandbox %s: %v", sb.ID(), err)
+			continue
+		}
+		if err := applyInfraContainerSpec(infra, &m); err != nil {
+			logrus.Warnf("failed to apply infra container spec for rehydrated sandbox %s: %v", sb.ID(), err)
+			continue
+		}
+		if err := c.refreshContainerStateFromDisk(infra); err != nil {
+			logrus.Warnf("failed to refresh infra container state for rehydrated sandbox %s: %v", sb.ID(), err)
+			continue
+		}
+		sb.RestoreStopped()
+	}
+
+	for _, ctr := range c.ListContainers() {
+		config, err := c.store.FromContainerDirectory(ctr.ID(), "config.json")
+		if err != nil {
+			logrus.Warnf("failed to read config.json for rehydrated container %s: %v", ctr.ID(), err)
+			continue
+		}
+		var m rspec.Spec
+		if err := json.Unmarshal(config, &m); err != nil {
+			logrus.Warnf("failed to unmarshal config.json for rehydrated container %s: %v", ctr.ID(), err)
+			continue
+		}
+		applyContainerSpec(ctr, &m)
+		if err := c.refreshContainerStateFromDisk(ctr); err != nil {
+			logrus.Warnf("failed to refresh state for rehydrated container %s: %v", ctr.ID(), err)
+		}
+	}
+}
+
+// LoadSandbox loads a sandbox from the disk into the sandbox store. It is a
+// fallback path: State.Rehydrate already restores every sandbox the state
+// database has a record for at startup, so this is only reached for an ID
+// found on disk with no such record, e.g. the first restart after an
+// upgrade from a State backend that didn't have one.
 func (c *ContainerServer) LoadSandbox(id string) (retErr error) {
 	config, err := c.store.FromContainerDirectory(id, "config.json")
 	if err != nil {
@@ -204,7 +412,7 @@ func (c *ContainerServer) LoadSandbox(id string) (retErr error) {
 
 	// We add an NS only if we can load a permanent one.
 	// Otherwise, the sandbox will live in the host namespace.
-	if c.config.ManageNSLifecycle {
+	if c.Config().ManageNSLifecycle {
 		netNsPath, err := configNsPath(&m, rspec.NetworkNamespace)
 		if err == nil {
 			if nsErr := sb.NetNsJoin(netNsPath); nsErr != nil {
@@ -272,27 +480,12 @@ func (c *ContainerServer) LoadSandbox(id string) (retErr error) {
 	if err != nil {
 		return err
 	}
-	scontainer.SetSpec(&m)
-	scontainer.SetMountPoint(m.Annotations[annotations.MountPoint])
-
-	if m.Annotations[annotations.Volumes] != "" {
-		containerVolumes := []oci.ContainerVolume{}
-		if err = json.Unmarshal([]byte(m.Annotations[annotations.Volumes]), &containerVolumes); err != nil {
-			return fmt.Errorf("failed to unmarshal container volumes: %v", err)
-		}
-		for _, cv := range containerVolumes {
-			scontainer.AddVolume(cv)
-		}
-	}
-
-	if err := c.ContainerStateFromDisk(scontainer); err != nil {
-		return fmt.Errorf("error reading sandbox state from disk %q: %v", scontainer.ID(), err)
+	if err := applyInfraContainerSpec(scontainer, &m); err != nil {
+		return err
 	}
 
-	// We write back the state because it is possible that crio did not have a chance to
-	// read the exit file and persist exit code into the state on reboot.
-	if err := c.ContainerStateToDisk(scontainer); err != nil {
-		return fmt.Errorf("failed to write container state to disk %q: %v", scontainer.ID(), err)
+	if err := c.refreshContainerStateFromDisk(scontainer); err != nil {
+		return err
 	}
 
 	sb.SetCreated()
@@ -332,7 +525,9 @@ func configNsPath(spec *rspec.Spec, nsType rspec.LinuxNamespaceType) (string, er
 
 var ErrIsNonCrioContainer = errors.New("non CRI-O container")
 
-// LoadContainer loads a container from the disk into the container store
+// LoadContainer loads a container from the disk into the container store.
+// Like LoadSandbox, it is only a fallback for an ID State.Rehydrate did not
+// already restore from the state database.
 func (c *ContainerServer) LoadContainer(id string) (retErr error) {
 	config, err := c.store.FromContainerDirectory(id, "config.json")
 	if err != nil {
@@ -416,19 +611,12 @@ func (c *ContainerServer) LoadContainer(id string) (retErr error) {
 	if err != nil {
 		return err
 	}
-	ctr.SetSpec(&m)
-	ctr.SetMountPoint(m.Annotations[annotations.MountPoint])
+	applyContainerSpec(ctr, &m)
 	spp := m.Annotations[annotations.SeccompProfilePath]
 	ctr.SetSeccompProfilePath(spp)
 
-	if err := c.ContainerStateFromDisk(ctr); err != nil {
-		return fmt.Errorf("error reading container state from disk %q: %v", ctr.ID(), err)
-	}
-
-	// We write back the state because it is possible that crio did not have a chance to
-	// read the exit file and persist exit code into the state on reboot.
-	if err := c.ContainerStateToDisk(ctr); err != nil {
-		return fmt.Errorf("failed to write container state to disk %q: %v", ctr.ID(), err)
+	if err := c.refreshContainerStateFromDisk(ctr); err != nil {
+		return err
 	}
 	ctr.SetCreated()
 
@@ -473,6 +661,101 @@ func (c *ContainerServer) ContainerStateToDisk(ctr *oci.Container) error {
 	return enc.Encode(ctr.State())
 }
 
+// rewriteNameAnnotation updates the annotations.Name field of id's on-disk
+// config.json to newName. It is the on-disk half of a rename, alongside
+// RenameContainer/RenameSandbox's name-index and in-memory updates.
+func (c *ContainerServer) rewriteNameAnnotation(id, newName string) error {
+	config, err := c.store.FromContainerDirectory(id, "config.json")
+	if err != nil {
+		return err
+	}
+	var m rspec.Spec
+	if err := json.Unmarshal(config, &m); err != nil {
+		return errors.Wrap(err, "error unmarshalling config.json")
+	}
+	m.Annotations[annotations.Name] = newName
+
+	containerDir, err := c.store.ContainerDirectory(id)
+	if err != nil {
+		return err
+	}
+	jsonSource, err := ioutils.NewAtomicFileWriter(filepath.Join(containerDir, "config.json"), 0644)
+	if err != nil {
+		return err
+	}
+	defer jsonSource.Close()
+	enc := json.NewEncoder(jsonSource)
+	return enc.Encode(&m)
+}
+
+// RenameContainer atomically renames ctr to newName: it reserves newName in
+// ctrNameIndex, rewrites the annotations.Name field of the container's
+// on-disk config.json, updates the in-memory oci.Container, and only then
+// releases the old name, rolling back whatever already succeeded if a
+// later step fails. It is the server-side half of crio's rename API,
+// ported from the old libkpod implementation, for when kubelet garbage
+// collection reuses a name and an operator wants to keep the old container
+// around for a post-mortem without disturbing the live pod.
+func (c *ContainerServer) RenameContainer(ctr *oci.Container, newName string) (retErr error) {
+	oldName := ctr.Name()
+
+	if _, err := c.ReserveContainerName(ctr.ID(), newName); err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			c.ReleaseContainerName(newName)
+		}
+	}()
+
+	if err := c.rewriteNameAnnotation(ctr.ID(), newName); err != nil {
+		return errors.Wrapf(err, "failed to rename container %s on disk", ctr.ID())
+	}
+
+	if err := c.state.RenameContainer(ctr.ID(), newName); err != nil {
+		if rbErr := c.rewriteNameAnnotation(ctr.ID(), oldName); rbErr != nil {
+			logrus.Warnf("failed to restore name of container %s on disk: %v", ctr.ID(), rbErr)
+		}
+		return errors.Wrapf(err, "failed to rename container %s in the state store", ctr.ID())
+	}
+
+	ctr.SetName(newName)
+	c.ReleaseContainerName(oldName)
+	return nil
+}
+
+// RenameSandbox is RenameContainer's sandbox-level counterpart: it renames
+// sb the same way, through podNameIndex and the sandbox's own config.json,
+// so the pod keeps a consistent name across its own listing and its infra
+// container's.
+func (c *ContainerServer) RenameSandbox(sb *sandbox.Sandbox, newName string) (retErr error) {
+	oldName := sb.Name()
+
+	if _, err := c.ReservePodName(sb.ID(), newName); err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			c.ReleasePodName(newName)
+		}
+	}()
+
+	if err := c.rewriteNameAnnotation(sb.ID(), newName); err != nil {
+		return errors.Wrapf(err, "failed to rename pod sandbox %s on disk", sb.ID())
+	}
+
+	if err := c.state.RenameSandbox(sb.ID(), newName); err != nil {
+		if rbErr := c.rewriteNameAnnotation(sb.ID(), oldName); rbErr != nil {
+			logrus.Warnf("failed to restore name of pod sandbox %s on disk: %v", sb.ID(), rbErr)
+		}
+		return errors.Wrapf(err, "failed to rename pod sandbox %s in the state store", sb.ID())
+	}
+
+	sb.SetName(newName)
+	c.ReleasePodName(oldName)
+	return nil
+}
+
 // ReserveContainerName holds a name for a container that is being created
 func (c *ContainerServer) ReserveContainerName(id, name string) (string, error) {
 	if err := c.ctrNameIndex.Reserve(name, id); err != nil {
@@ -516,6 +799,12 @@ func recoverLogError() {
 // Shutdown attempts to shut down the server's storage cleanly
 func (c *ContainerServer) Shutdown() error {
 	defer recoverLogError()
+	if err := c.state.Close(); err != nil {
+		logrus.Warnf("error closing state: %v", err)
+	}
+	if err := c.events.Close(); err != nil {
+		logrus.Warnf("error closing events journal: %v", err)
+	}
 	_, err := c.store.Shutdown(false)
 	if err != nil && errors.Cause(err) != cstorage.ErrLayerUsedByContainer {
 		return err
@@ -523,63 +812,81 @@ func (c *ContainerServer) Shutdown() error {
 	return nil
 }
 
-type containerServerState struct {
-	containers      oci.ContainerStorer
-	infraContainers oci.ContainerStorer
-	sandboxes       sandbox.Storer
-	// processLevels The number of sandboxes using the same SELinux MCS level. Need to release MCS Level, when count reaches 0
-	processLevels map[string]int
-}
-
 // AddContainer adds a container to the container state store
 func (c *ContainerServer) AddContainer(ctr *oci.Container) {
-	newSandbox := c.state.sandboxes.Get(ctr.Sandbox())
+	newSandbox := c.state.GetSandbox(ctr.Sandbox())
 	if newSandbox == nil {
 		return
 	}
 	newSandbox.AddContainer(ctr)
-	c.state.containers.Add(ctr.ID(), ctr)
+	if err := c.state.AddContainer(ctr); err != nil {
+		logrus.Errorf("could not persist container %s: %v", ctr.ID(), err)
+	}
+	c.emitEvent(events.Event{
+		Type:   events.Container,
+		Status: events.Create,
+		ID:     ctr.ID(),
+		Name:   ctr.Name(),
+		Image:  ctr.Image(),
+		PodID:  ctr.Sandbox(),
+		Labels: ctr.Labels(),
+	})
 }
 
 // AddInfraContainer adds a container to the container state store
 func (c *ContainerServer) AddInfraContainer(ctr *oci.Container) {
-	c.state.infraContainers.Add(ctr.ID(), ctr)
+	if err := c.state.AddInfraContainer(ctr); err != nil {
+		logrus.Errorf("could not persist infra container %s: %v", ctr.ID(), err)
+	}
 }
 
 // GetContainer returns a container by its ID
 func (c *ContainerServer) GetContainer(id string) *oci.Container {
-	return c.state.containers.Get(id)
+	return c.state.GetContainer(id)
 }
 
 // GetInfraContainer returns a container by its ID
 func (c *ContainerServer) GetInfraContainer(id string) *oci.Container {
-	return c.state.infraContainers.Get(id)
+	return c.state.GetInfraContainer(id)
 }
 
 // HasContainer checks if a container exists in the state
 func (c *ContainerServer) HasContainer(id string) bool {
-	return c.state.containers.Get(id) != nil
+	return c.state.HasContainer(id)
 }
 
 // RemoveContainer removes a container from the container state store
 func (c *ContainerServer) RemoveContainer(ctr *oci.Container) {
 	sbID := ctr.Sandbox()
-	sb := c.state.sandboxes.Get(sbID)
+	sb := c.state.GetSandbox(sbID)
 	if sb == nil {
 		return
 	}
 	sb.RemoveContainer(ctr)
-	c.state.containers.Delete(ctr.ID())
+	if err := c.state.RemoveContainer(ctr); err != nil {
+		logrus.Errorf("could not remove persisted container %s: %v", ctr.ID(), err)
+	}
+	c.emitEvent(events.Event{
+		Type:   events.Container,
+		Status: events.Remove,
+		ID:     ctr.ID(),
+		Name:   ctr.Name(),
+		Image:  ctr.Image(),
+		PodID:  ctr.Sandbox(),
+		Labels: ctr.Labels(),
+	})
 }
 
 // RemoveInfraContainer removes a container from the container state store
 func (c *ContainerServer) RemoveInfraContainer(ctr *oci.Container) {
-	c.state.infraContainers.Delete(ctr.ID())
+	if err := c.state.RemoveInfraContainer(ctr); err != nil {
+		logrus.Errorf("could not remove persisted infra container %s: %v", ctr.ID(), err)
+	}
 }
 
 // listContainers returns a list of all containers stored by the server state
 func (c *ContainerServer) listContainers() []*oci.Container {
-	return c.state.containers.List()
+	return c.state.ListContainers()
 }
 
 // ListContainers returns a list of all containers stored by the server state
@@ -602,21 +909,44 @@ func (c *ContainerServer) ListContainers(filters ...func(*oci.Container) bool) (
 
 // AddSandbox adds a sandbox to the sandbox state store
 func (c *ContainerServer) AddSandbox(sb *sandbox.Sandbox) error {
-	c.state.sandboxes.Add(sb.ID(), sb)
+	locker, err := c.locks.AllocateLock(sb.ID())
+	if err != nil {
+		return errors.Wrapf(err, "failed to allocate lock for sandbox %s", sb.ID())
+	}
+	if err := locker.Lock(); err != nil {
+		return errors.Wrapf(err, "failed to lock sandbox %s", sb.ID())
+	}
+	defer locker.Unlock() // nolint:errcheck
+
+	if slot, ok := c.locks.Slot(sb.ID()); ok {
+		sb.SetLockSlot(slot)
+	}
+
+	if err := c.state.AddSandbox(sb); err != nil {
+		return err
+	}
 
-	c.stateLock.Lock()
-	defer c.stateLock.Unlock()
-	return c.addSandboxPlatform(sb)
+	if err := c.addSandboxPlatform(sb); err != nil {
+		return err
+	}
+
+	c.emitEvent(events.Event{
+		Type:   events.Sandbox,
+		Status: events.Create,
+		ID:     sb.ID(),
+		Labels: sb.Labels(),
+	})
+	return nil
 }
 
 // GetSandbox returns a sandbox by its ID
 func (c *ContainerServer) GetSandbox(id string) *sandbox.Sandbox {
-	return c.state.sandboxes.Get(id)
+	return c.state.GetSandbox(id)
 }
 
 // GetSandboxContainer returns a sandbox's infra container
 func (c *ContainerServer) GetSandboxContainer(id string) *oci.Container {
-	sb := c.state.sandboxes.Get(id)
+	sb := c.state.GetSandbox(id)
 	if sb == nil {
 		return nil
 	}
@@ -625,38 +955,194 @@ func (c *ContainerServer) GetSandboxContainer(id string) *oci.Container {
 
 // HasSandbox checks if a sandbox exists in the state
 func (c *ContainerServer) HasSandbox(id string) bool {
-	return c.state.sandboxes.Get(id) != nil
+	return c.state.HasSandbox(id)
 }
 
 // RemoveSandbox removes a sandbox from the state store
 func (c *ContainerServer) RemoveSandbox(id string) error {
-	sb := c.state.sandboxes.Get(id)
+	sb := c.state.GetSandbox(id)
 	if sb == nil {
 		return nil
 	}
 
-	c.stateLock.Lock()
-	defer c.stateLock.Unlock()
+	locker, err := c.locks.AllocateLock(id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to allocate lock for sandbox %s", id)
+	}
+	if err := locker.Lock(); err != nil {
+		return errors.Wrapf(err, "failed to lock sandbox %s", id)
+	}
+	// unlocked is set once Unlock has run on the success path below, so
+	// the deferred Unlock here only fires on an early return. Without
+	// it, the deferred Unlock would still run after Free() on the
+	// success path, by which point a concurrent AllocateLock for a
+	// different ID may have already claimed and locked the freed slot,
+	// and the deferred call would strip that lock out from under it.
+	unlocked := false
+	defer func() {
+		if !unlocked {
+			locker.Unlock() // nolint:errcheck
+		}
+	}()
+
 	if err := c.removeSandboxPlatform(sb); err != nil {
 		return err
 	}
 
-	c.state.sandboxes.Delete(id)
-	return nil
+	if err := c.state.RemoveSandbox(id); err != nil {
+		return err
+	}
+
+	c.emitEvent(events.Event{
+		Type:   events.Sandbox,
+		Status: events.Remove,
+		ID:     sb.ID(),
+		Labels: sb.Labels(),
+	})
+
+	if err := locker.Unlock(); err != nil {
+		return errors.Wrapf(err, "failed to unlock sandbox %s", id)
+	}
+	unlocked = true
+
+	return locker.Free()
 }
 
 // ListSandboxes lists all sandboxes in the state store
 func (c *ContainerServer) ListSandboxes() []*sandbox.Sandbox {
-	return c.state.sandboxes.List()
+	return c.state.ListSandboxes()
 }
 
 // StopContainerAndWait is a wrapping function that stops a container and waits for the container state to be stopped
 func (c *ContainerServer) StopContainerAndWait(ctx context.Context, ctr *oci.Container, timeout int64) error {
+	locker, err := c.locks.AllocateLock(ctr.ID())
+	if err != nil {
+		return fmt.Errorf("failed to allocate lock for container %s: %v", ctr.Name(), err)
+	}
+	if err := locker.Lock(); err != nil {
+		return fmt.Errorf("failed to lock container %s: %v", ctr.Name(), err)
+	}
+	defer locker.Unlock() // nolint:errcheck
+
 	if err := c.Runtime().StopContainer(ctx, ctr, timeout); err != nil {
 		return fmt.Errorf("failed to stop container %s: %v", ctr.Name(), err)
 	}
 	if err := c.Runtime().WaitContainerStateStopped(ctx, ctr); err != nil {
 		return fmt.Errorf("failed to get container 'stopped' status %s: %v", ctr.Name(), err)
 	}
+	c.emitEvent(events.Event{
+		Type:   events.Container,
+		Status: events.Stop,
+		ID:     ctr.ID(),
+		Name:   ctr.Name(),
+		Image:  ctr.Image(),
+		PodID:  ctr.Sandbox(),
+		Labels: ctr.Labels(),
+	})
 	return nil
 }
+
+// CheckpointPodSandbox checkpoints every container known to the sandbox id
+// (infra container first), plus the sandbox's own metadata, into a single
+// pod-level archive that RestorePodSandbox can later recreate the sandbox
+// from.
+func (c *ContainerServer) CheckpointPodSandbox(ctx context.Context, id string, opts sandbox.CheckpointOptions) (string, error) {
+	sb := c.GetSandbox(id)
+	if sb == nil {
+		return "", fmt.Errorf("no such pod sandbox %s", id)
+	}
+	archivePath, err := sb.Checkpoint(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	c.emitEvent(events.Event{
+		Type:   events.Sandbox,
+		Status: events.Checkpoint,
+		ID:     sb.ID(),
+		Labels: sb.Labels(),
+	})
+	return archivePath, nil
+}
+
+// RestorePodSandbox recreates a sandbox from a pod-level archive written by
+// CheckpointPodSandbox: it reserves the pod and container names, rebuilds
+// the sandbox and its containers from the archive's pod.spec.dump manifest,
+// adds the sandbox to the server's state, and then restores each container
+// in the order the manifest recorded (infra container first). The restored
+// containers are attached to the sandbox only; they are not added to the
+// server's container state.
+func (c *ContainerServer) RestorePodSandbox(ctx context.Context, archivePath string, opts sandbox.RestoreOptions) (retSb *sandbox.Sandbox, retErr error) {
+	spec, err := sandbox.ReadPodSpecDump(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read pod checkpoint manifest")
+	}
+
+	name, err := c.ReservePodName(spec.ID, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			c.ReleasePodName(name)
+		}
+	}()
+
+	sb, err := sandbox.New(spec.ID, spec.Namespace, name, spec.KubeName, spec.LogDir, spec.Labels, spec.KubeAnnotations,
+		spec.ProcessLabel, spec.MountLabel, spec.Metadata, spec.ShmPath, spec.CgroupParent, spec.Privileged,
+		spec.RuntimeHandler, spec.ResolvPath, spec.Hostname, spec.PortMappings, spec.HostNetwork)
+	if err != nil {
+		return nil, err
+	}
+	sb.SetNamespaceOptions(spec.NamespaceOptions)
+
+	for _, cs := range spec.Containers {
+		cname, err := c.ReserveContainerName(cs.ID, cs.Name)
+		if err != nil {
+			return nil, err
+		}
+		defer func(cname string) {
+			if retErr != nil {
+				c.ReleaseContainerName(cname)
+			}
+		}(cname)
+
+		ctr, err := oci.NewContainer(cs.ID, cname, cs.BundlePath, cs.LogPath, cs.Labels, cs.Annotations, cs.KubeAnnotations,
+			cs.Image, cs.ImageName, cs.ImageRef, cs.Metadata, spec.ID, cs.Terminal, cs.Stdin, cs.StdinOnce,
+			cs.RuntimeHandler, cs.Dir, cs.Created, cs.StopSignal)
+		if err != nil {
+			return nil, err
+		}
+
+		if cs.IsInfra {
+			if err := sb.SetInfraContainer(ctr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		sb.AddContainer(ctr)
+	}
+
+	if err := c.AddSandbox(sb); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			if err := c.RemoveSandbox(sb.ID()); err != nil {
+				logrus.Warnf("could not remove sandbox ID %s: %v", sb.ID(), err)
+			}
+		}
+	}()
+
+	if err := sb.Restore(ctx, archivePath, opts); err != nil {
+		return nil, err
+	}
+
+	c.emitEvent(events.Event{
+		Type:   events.Sandbox,
+		Status: events.Restore,
+		ID:     sb.ID(),
+		Labels: sb.Labels(),
+	})
+
+	return sb, nil
+}
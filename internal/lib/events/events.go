@@ -0,0 +1,102 @@
+// Package events defines the lifecycle event crio emits as it creates,
+// starts, stops, removes, checkpoints and restores sandboxes and
+// containers, and the Journal backends that record and replay them. It is
+// modeled on libpod's events package: a small, typed Event plus a Journal
+// interface with interchangeable backends, rather than a single hardcoded
+// log format.
+package events
+
+import "time"
+
+// Type is the kind of object an Event describes.
+type Type string
+
+const (
+	// Container marks an Event about a single container.
+	Container Type = "container"
+	// Sandbox marks an Event about a pod sandbox as a whole.
+	Sandbox Type = "sandbox"
+)
+
+// Status is the lifecycle transition an Event records.
+type Status string
+
+const (
+	Create     Status = "create"
+	Start      Status = "start"
+	Stop       Status = "stop"
+	Remove     Status = "remove"
+	Checkpoint Status = "checkpoint"
+	Restore    Status = "restore"
+)
+
+// Event is a single lifecycle transition of a sandbox or container.
+type Event struct {
+	Type   Type
+	Status Status
+	ID     string
+	Name   string
+	Image  string
+	PodID  string
+	Labels map[string]string
+	Time   time.Time
+}
+
+// Filter reports whether an Event should be delivered to a Watch call. A
+// nil Filter slice matches every Event.
+type Filter func(*Event) bool
+
+// TypeFilter matches events whose Type is one of types. An empty types
+// matches every type.
+func TypeFilter(types ...Type) Filter {
+	return func(e *Event) bool {
+		if len(types) == 0 {
+			return true
+		}
+		for _, t := range types {
+			if e.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StatusFilter matches events whose Status is one of statuses. An empty
+// statuses matches every status.
+func StatusFilter(statuses ...Status) Filter {
+	return func(e *Event) bool {
+		if len(statuses) == 0 {
+			return true
+		}
+		for _, s := range statuses {
+			if e.Status == s {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// LabelFilter matches events whose Labels contain every key/value pair in
+// want.
+func LabelFilter(want map[string]string) Filter {
+	return func(e *Event) bool {
+		for k, v := range want {
+			if e.Labels[k] != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Match reports whether e satisfies every filter.
+func Match(e *Event, filters []Filter) bool {
+	for _, f := range filters {
+		if !f(e) {
+			return false
+		}
+	}
+	return true
+}
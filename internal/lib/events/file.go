@@ -0,0 +1,103 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FileJournal is a Journal backed by a JSON-lines file, one Event per line,
+// so an off-the-shelf log shipper can follow it without understanding
+// crio's wire format.
+type FileJournal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileJournal opens (creating if necessary) the JSON-lines event log at
+// path.
+func NewFileJournal(path string) (*FileJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, errors.Wrap(err, "failed to create events log directory")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open events log %s", path)
+	}
+	return &FileJournal{path: path, file: f}, nil
+}
+
+// Write appends ev to the log as a single JSON line.
+func (j *FileJournal) Write(ev Event) error {
+	data, err := json.Marshal(&ev)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(data, '\n'))
+	return errors.Wrap(err, "failed to write event")
+}
+
+// Stream replays every event already in the log that matches filters, then
+// polls once a second for newly appended ones until ctx is done. Polling
+// rather than watching the file with inotify keeps this dependency-free:
+// events are a low-rate audit trail, not a hot path.
+func (j *FileJournal) Stream(ctx context.Context, filters []Filter, out chan<- Event) error {
+	seen := 0
+	deliver := func() error {
+		f, err := os.Open(j.path)
+		if err != nil {
+			return errors.Wrap(err, "failed to open events log")
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if line <= seen {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+				continue
+			}
+			if Match(&ev, filters) {
+				out <- ev
+			}
+		}
+		seen = line
+		return scanner.Err()
+	}
+
+	if err := deliver(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := deliver(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close closes the underlying log file.
+func (j *FileJournal) Close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,31 @@
+package events
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// Journal records lifecycle Events and lets callers watch them, so
+// operators can plug crio into an audit pipeline and test harnesses can
+// assert against event traces instead of polling ListContainers.
+type Journal interface {
+	// Write appends ev to the journal.
+	Write(ev Event) error
+	// Stream delivers every Event matching filters to out: first the
+	// journal's existing history, then, until ctx is done, every Event
+	// written after Stream was called. It is the caller's responsibility
+	// to keep draining out until Stream returns.
+	Stream(ctx context.Context, filters []Filter, out chan<- Event) error
+	// Close releases any resources the Journal holds open.
+	Close() error
+}
+
+// NewJournal returns the Journal backend named by logger. "journald" uses
+// the systemd journal; anything else, including the empty string, falls
+// back to a JSON-lines file under logDir.
+func NewJournal(logger, logDir string) (Journal, error) {
+	if logger == "journald" {
+		return newJournaldJournal()
+	}
+	return NewFileJournal(filepath.Join(logDir, "events.log"))
+}
@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"sync"
+
+	"github.com/cri-o/cri-o/internal/lib/sandbox"
+	"github.com/cri-o/cri-o/internal/oci"
+)
+
+// InMemoryState is the State ContainerServer has always used: every
+// sandbox and container lives only in the stores below, so a restart
+// starts from nothing and relies entirely on LoadSandbox/LoadContainer to
+// rebuild it from disk.
+type InMemoryState struct {
+	containers      oci.ContainerStorer
+	infraContainers oci.ContainerStorer
+	sandboxes       sandbox.Storer
+
+	// processLevelsMu guards processLevels. Unlike the stores above, a
+	// single SELinux MCS level can be shared across sandboxes with
+	// unrelated IDs, so it can't rely on the per-ID locking
+	// lock.Manager.AllocateLock gives AddSandbox/RemoveSandbox.
+	processLevelsMu sync.Mutex
+	// processLevels is the number of sandboxes using the same SELinux MCS
+	// level. The level is released once its count reaches 0.
+	processLevels map[string]int
+}
+
+// NewInMemoryState creates an empty InMemoryState.
+func NewInMemoryState() *InMemoryState {
+	return &InMemoryState{
+		containers:      oci.NewMemoryStore(),
+		infraContainers: oci.NewMemoryStore(),
+		sandboxes:       sandbox.NewMemoryStore(),
+		processLevels:   make(map[string]int),
+	}
+}
+
+// AddSandbox adds a sandbox to the sandbox store.
+func (s *InMemoryState) AddSandbox(sb *sandbox.Sandbox) error {
+	s.sandboxes.Add(sb.ID(), sb)
+	return nil
+}
+
+// GetSandbox returns a sandbox by its ID.
+func (s *InMemoryState) GetSandbox(id string) *sandbox.Sandbox {
+	return s.sandboxes.Get(id)
+}
+
+// HasSandbox checks if a sandbox exists in the state.
+func (s *InMemoryState) HasSandbox(id string) bool {
+	return s.sandboxes.Get(id) != nil
+}
+
+// RemoveSandbox removes a sandbox from the state.
+func (s *InMemoryState) RemoveSandbox(id string) error {
+	s.sandboxes.Delete(id)
+	return nil
+}
+
+// ListSandboxes lists all sandboxes in the state.
+func (s *InMemoryState) ListSandboxes() []*sandbox.Sandbox {
+	return s.sandboxes.List()
+}
+
+// RenameSandbox is a no-op: InMemoryState's store holds the same
+// *sandbox.Sandbox the caller already renamed via SetName.
+func (s *InMemoryState) RenameSandbox(id, newName string) error {
+	return nil
+}
+
+// AddContainer adds a container to the container state store.
+func (s *InMemoryState) AddContainer(ctr *oci.Container) error {
+	s.containers.Add(ctr.ID(), ctr)
+	return nil
+}
+
+// AddInfraContainer adds an infra container to the container state store.
+func (s *InMemoryState) AddInfraContainer(ctr *oci.Container) error {
+	s.infraContainers.Add(ctr.ID(), ctr)
+	return nil
+}
+
+// GetContainer returns a container by its ID.
+func (s *InMemoryState) GetContainer(id string) *oci.Container {
+	return s.containers.Get(id)
+}
+
+// GetInfraContainer returns an infra container by its ID.
+func (s *InMemoryState) GetInfraContainer(id string) *oci.Container {
+	return s.infraContainers.Get(id)
+}
+
+// HasContainer checks if a container exists in the state.
+func (s *InMemoryState) HasContainer(id string) bool {
+	return s.containers.Get(id) != nil
+}
+
+// RemoveContainer removes a container from the container state store.
+func (s *InMemoryState) RemoveContainer(ctr *oci.Container) error {
+	s.containers.Delete(ctr.ID())
+	return nil
+}
+
+// RemoveInfraContainer removes an infra container from the container state store.
+func (s *InMemoryState) RemoveInfraContainer(ctr *oci.Container) error {
+	s.infraContainers.Delete(ctr.ID())
+	return nil
+}
+
+// ListContainers returns every container in the state.
+func (s *InMemoryState) ListContainers() []*oci.Container {
+	return s.containers.List()
+}
+
+// RenameContainer is a no-op: InMemoryState's store holds the same
+// *oci.Container the caller already renamed via SetName.
+func (s *InMemoryState) RenameContainer(id, newName string) error {
+	return nil
+}
+
+// ReserveProcessLevel records one more sandbox using level.
+func (s *InMemoryState) ReserveProcessLevel(level string) {
+	s.processLevelsMu.Lock()
+	defer s.processLevelsMu.Unlock()
+	s.processLevels[level]++
+}
+
+// ReleaseProcessLevel records one fewer sandbox using level and returns the
+// remaining count.
+func (s *InMemoryState) ReleaseProcessLevel(level string) int {
+	s.processLevelsMu.Lock()
+	defer s.processLevelsMu.Unlock()
+	s.processLevels[level]--
+	count := s.processLevels[level]
+	if count <= 0 {
+		delete(s.processLevels, level)
+	}
+	return count
+}
+
+// Close is a no-op: InMemoryState holds no external resources.
+func (s *InMemoryState) Close() error {
+	return nil
+}
@@ -0,0 +1,220 @@
+// Package lock provides a per-sandbox/container file lock manager, so
+// ContainerServer can serialize an operation against a single ID instead of
+// taking one global mutex that blocks every unrelated sandbox add/remove.
+//
+// Locks are backed by fcntl(2) byte-range locks on a single on-disk file,
+// one byte per slot, rather than flock(2), which can only lock a whole
+// file and so can't give each ID its own independent lock within a shared
+// file. Because the file lives under the runtime directory rather than in
+// this process's memory, it survives a crio restart: a second crio process,
+// or a crictl-triggered operation racing with kubelet reconciliation, sees
+// the same lock state instead of finding everything unlocked.
+package lock
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Locker is a single named lock allocated by a Manager.
+type Locker interface {
+	Lock() error
+	Unlock() error
+	// Free releases the slot backing this Locker back to its Manager, so
+	// it can be handed out to a different ID. The Locker must not be used
+	// again afterward.
+	Free() error
+}
+
+const bitsPerByte = 8
+
+// Manager allocates a stable numeric slot per ID and returns a Locker
+// backed by a byte of a fixed-size file, so locks for unrelated IDs never
+// contend with each other. The first ceil(maxLocks/8) bytes of the file are
+// a bitmap of which slots are currently allocated; the remainder is one
+// lockable byte per slot.
+type Manager struct {
+	mu         sync.Mutex
+	file       *os.File
+	maxLocks   uint32
+	bitmapLen  int64
+	lockOffset int64
+	bitmap     []byte
+	slots      map[string]uint32
+	// slotLocks pairs each slot's fcntl byte-range lock with an in-process
+	// sync.Mutex, one per slot. fcntl(2) locks are scoped to (process,
+	// inode), not per-goroutine: two goroutines in this same process both
+	// calling Lock() on the same slot would both succeed immediately,
+	// since the second F_SETLKW is a no-op against a lock its own process
+	// already holds. The fcntl lock alone only serializes across
+	// processes; slotLocks adds back the serialization within one.
+	slotLocks []sync.Mutex
+}
+
+// NewManager opens (creating if necessary) the lock file at path, sized for
+// maxLocks slots, and returns a Manager over it.
+func NewManager(path string, maxLocks uint32) (*Manager, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, errors.Wrap(err, "failed to create lock directory")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open lock file %s", path)
+	}
+
+	bitmapLen := int64((maxLocks + bitsPerByte - 1) / bitsPerByte)
+	size := bitmapLen + int64(maxLocks)
+	if err := f.Truncate(size); err != nil {
+		f.Close() // nolint:errcheck
+		return nil, errors.Wrap(err, "failed to size lock file")
+	}
+
+	bitmap := make([]byte, bitmapLen)
+	if _, err := f.ReadAt(bitmap, 0); err != nil && err != io.EOF {
+		f.Close() // nolint:errcheck
+		return nil, errors.Wrap(err, "failed to read lock bitmap")
+	}
+
+	return &Manager{
+		file:       f,
+		maxLocks:   maxLocks,
+		bitmapLen:  bitmapLen,
+		lockOffset: bitmapLen,
+		bitmap:     bitmap,
+		slots:      make(map[string]uint32),
+		slotLocks:  make([]sync.Mutex, maxLocks),
+	}, nil
+}
+
+// AllocateLock returns the Locker for id, allocating it a free slot first if
+// id has never been seen by this Manager before. Calling it again for an
+// already-allocated id returns a Locker for the same slot.
+func (m *Manager) AllocateLock(id string) (Locker, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slot, ok := m.slots[id]
+	if !ok {
+		found, err := m.allocateSlotLocked()
+		if err != nil {
+			return nil, err
+		}
+		slot = found
+		m.slots[id] = slot
+	}
+	return &fileLocker{manager: m, id: id, slot: slot, offset: m.lockOffset + int64(slot)}, nil
+}
+
+// Slot returns the slot currently allocated to id, if any. Callers use it
+// after AllocateLock to learn the numeric slot so it can be saved alongside
+// the id's persisted record, for a later RestoreSlot across a restart.
+func (m *Manager) Slot(id string) (uint32, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slot, ok := m.slots[id]
+	return slot, ok
+}
+
+// RestoreSlot re-registers an id/slot pair a caller already knows about
+// (e.g. from a persisted sandbox or container record), marking the slot
+// used without picking a new one, so an ID keeps the same slot across a
+// restart instead of silently migrating to a new one.
+func (m *Manager) RestoreSlot(id string, slot uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slot >= m.maxLocks {
+		return errors.Errorf("slot %d is out of range for %d locks", slot, m.maxLocks)
+	}
+	m.setBit(slot, true)
+	m.slots[id] = slot
+	return m.writeBitmapLocked()
+}
+
+// FreeSlot releases id's slot back to the free pool. It is a no-op if id
+// has no allocated slot.
+func (m *Manager) FreeSlot(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slot, ok := m.slots[id]
+	if !ok {
+		return nil
+	}
+	delete(m.slots, id)
+	m.setBit(slot, false)
+	return m.writeBitmapLocked()
+}
+
+func (m *Manager) allocateSlotLocked() (uint32, error) {
+	for slot := uint32(0); slot < m.maxLocks; slot++ {
+		if !m.bit(slot) {
+			m.setBit(slot, true)
+			if err := m.writeBitmapLocked(); err != nil {
+				return 0, err
+			}
+			return slot, nil
+		}
+	}
+	return 0, errors.Errorf("no free lock slots available (max %d)", m.maxLocks)
+}
+
+func (m *Manager) bit(slot uint32) bool {
+	return m.bitmap[slot/bitsPerByte]&(1<<(slot%bitsPerByte)) != 0
+}
+
+func (m *Manager) setBit(slot uint32, used bool) {
+	mask := byte(1 << (slot % bitsPerByte))
+	if used {
+		m.bitmap[slot/bitsPerByte] |= mask
+	} else {
+		m.bitmap[slot/bitsPerByte] &^= mask
+	}
+}
+
+func (m *Manager) writeBitmapLocked() error {
+	_, err := m.file.WriteAt(m.bitmap, 0)
+	return errors.Wrap(err, "failed to write lock bitmap")
+}
+
+// Close closes the underlying lock file.
+func (m *Manager) Close() error {
+	return m.file.Close()
+}
+
+// fileLocker is the Locker handed out by a Manager: an fcntl byte-range
+// lock on a single byte of the Manager's shared file.
+type fileLocker struct {
+	manager *Manager
+	id      string
+	slot    uint32
+	offset  int64
+}
+
+func (l *fileLocker) Lock() error {
+	l.manager.slotLocks[l.slot].Lock()
+	lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart, Start: l.offset, Len: 1}
+	if err := unix.FcntlFlock(l.manager.file.Fd(), unix.F_SETLKW, &lk); err != nil {
+		l.manager.slotLocks[l.slot].Unlock()
+		return err
+	}
+	return nil
+}
+
+func (l *fileLocker) Unlock() error {
+	lk := unix.Flock_t{Type: unix.F_UNLCK, Whence: io.SeekStart, Start: l.offset, Len: 1}
+	err := unix.FcntlFlock(l.manager.file.Fd(), unix.F_SETLK, &lk)
+	l.manager.slotLocks[l.slot].Unlock()
+	return err
+}
+
+func (l *fileLocker) Free() error {
+	return l.manager.FreeSlot(l.id)
+}
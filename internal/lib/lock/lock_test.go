@@ -0,0 +1,180 @@
+package lock
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "locks")
+	m, err := NewManager(path, 8)
+	if err != nil {
+		t.Fatalf("NewManager() = %v; want nil", err)
+	}
+	t.Cleanup(func() { m.Close() }) // nolint:errcheck
+	return m
+}
+
+func TestAllocateLockReusesSlotForSameID(t *testing.T) {
+	m := newTestManager(t)
+
+	if _, err := m.AllocateLock("a"); err != nil {
+		t.Fatalf("AllocateLock() = %v; want nil", err)
+	}
+	slotA, ok := m.Slot("a")
+	if !ok {
+		t.Fatalf("Slot(a) not found after AllocateLock")
+	}
+
+	if _, err := m.AllocateLock("a"); err != nil {
+		t.Fatalf("second AllocateLock() = %v; want nil", err)
+	}
+	if slot, _ := m.Slot("a"); slot != slotA {
+		t.Errorf("Slot(a) = %d after re-allocating; want unchanged %d", slot, slotA)
+	}
+}
+
+func TestFreeSlotAllowsReuse(t *testing.T) {
+	m := newTestManager(t)
+
+	locker, err := m.AllocateLock("a")
+	if err != nil {
+		t.Fatalf("AllocateLock() = %v; want nil", err)
+	}
+	slotA, _ := m.Slot("a")
+
+	if err := locker.Free(); err != nil {
+		t.Fatalf("Free() = %v; want nil", err)
+	}
+	if _, ok := m.Slot("a"); ok {
+		t.Errorf("Slot(a) still present after Free")
+	}
+
+	if _, err := m.AllocateLock("b"); err != nil {
+		t.Fatalf("AllocateLock(b) = %v; want nil", err)
+	}
+	if slotB, _ := m.Slot("b"); slotB != slotA {
+		t.Errorf("Slot(b) = %d; want freed slot %d to be reused", slotB, slotA)
+	}
+}
+
+// TestUnlockThenFreeDoesNotStripConcurrentHolder exercises the ordering
+// RemoveSandbox relies on: Unlock must complete before Free releases the
+// slot, or a concurrent AllocateLock for a different ID can claim and lock
+// the freed slot before the original holder's Unlock runs, stripping the
+// new holder's lock out from under it.
+func TestUnlockThenFreeDoesNotStripConcurrentHolder(t *testing.T) {
+	m := newTestManager(t)
+
+	lockerA, err := m.AllocateLock("a")
+	if err != nil {
+		t.Fatalf("AllocateLock(a) = %v; want nil", err)
+	}
+	if err := lockerA.Lock(); err != nil {
+		t.Fatalf("Lock(a) = %v; want nil", err)
+	}
+
+	// Mirrors the fixed RemoveSandbox ordering: Unlock completes, then
+	// Free releases the slot.
+	if err := lockerA.Unlock(); err != nil {
+		t.Fatalf("Unlock(a) = %v; want nil", err)
+	}
+	if err := lockerA.Free(); err != nil {
+		t.Fatalf("Free(a) = %v; want nil", err)
+	}
+
+	lockerB, err := m.AllocateLock("b")
+	if err != nil {
+		t.Fatalf("AllocateLock(b) = %v; want nil", err)
+	}
+	if err := lockerB.Lock(); err != nil {
+		t.Fatalf("Lock(b) = %v; want nil", err)
+	}
+	defer lockerB.Unlock() // nolint:errcheck
+
+	// If a had still held its lock, a second Lock on the reused slot
+	// would block or fail; it must succeed here since a's Unlock ran
+	// before b ever allocated the slot.
+}
+
+// TestLockSerializesSameProcessCallers guards against the fcntl-only
+// implementation, which is scoped to (process, inode): a second goroutine's
+// F_SETLKW on an already-held slot is a no-op against a lock its own
+// process already holds, so it would return immediately instead of
+// blocking. Lock must still serialize two goroutines in this process
+// against each other.
+func TestLockSerializesSameProcessCallers(t *testing.T) {
+	m := newTestManager(t)
+
+	lockerA, err := m.AllocateLock("a")
+	if err != nil {
+		t.Fatalf("AllocateLock() = %v; want nil", err)
+	}
+	if err := lockerA.Lock(); err != nil {
+		t.Fatalf("Lock() = %v; want nil", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if err := lockerA.Lock(); err != nil {
+			t.Errorf("second Lock() = %v; want nil", err)
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second Lock() on an already-held slot returned before the first Unlock()")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := lockerA.Unlock(); err != nil {
+		t.Fatalf("Unlock() = %v; want nil", err)
+	}
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second Lock() never acquired the slot after Unlock()")
+	}
+	lockerA.Unlock() // nolint:errcheck
+}
+
+func TestConcurrentAllocateAndFreeAreSerialized(t *testing.T) {
+	m := newTestManager(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := string(rune('a' + i%26))
+			locker, err := m.AllocateLock(id)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := locker.Lock(); err != nil {
+				errs <- err
+				return
+			}
+			if err := locker.Unlock(); err != nil {
+				errs <- err
+				return
+			}
+			errs <- locker.Free()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent allocate/lock/unlock/free = %v; want nil", err)
+		}
+	}
+}
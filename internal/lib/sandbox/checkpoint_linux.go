@@ -0,0 +1,388 @@
+// +build linux
+
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/cri-o/cri-o/internal/oci"
+	"github.com/pkg/errors"
+	pb "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+	"k8s.io/kubernetes/pkg/kubelet/dockershim/network/hostport"
+)
+
+// podSpecDumpFile is the manifest Checkpoint writes into the archive
+// alongside the per-container CRIU images, so Restore (or a caller
+// recreating the sandbox from scratch via ReadPodSpecDump) doesn't have to
+// guess the sandbox's metadata or its containers' checkpoint order.
+const podSpecDumpFile = "pod.spec.dump"
+
+// ContainerSpec is the durable description of one checkpointed container,
+// mirroring oci.NewContainer's positional arguments closely enough that a
+// caller who only has a PodSpecDump can reconstruct the oci.Container
+// without re-deriving it from the container's on-disk config.json.
+type ContainerSpec struct {
+	ID              string
+	Name            string
+	BundlePath      string
+	LogPath         string
+	Labels          map[string]string
+	Annotations     map[string]string
+	KubeAnnotations map[string]string
+	Image           string
+	ImageName       string
+	ImageRef        string
+	Metadata        *oci.Metadata
+	Terminal        bool
+	Stdin           bool
+	StdinOnce       bool
+	RuntimeHandler  string
+	Dir             string
+	Created         time.Time
+	StopSignal      string
+	// IsInfra marks the container a caller should hand back to
+	// SetInfraContainer rather than AddContainer.
+	IsInfra bool
+}
+
+// PodSpecDump is the durable snapshot of a sandbox.Sandbox that Checkpoint
+// writes to podSpecDumpFile, mirroring sandbox.New's positional arguments
+// plus its NamespaceOptions, so a pod-level checkpoint archive carries
+// everything needed to recreate the sandbox on restore instead of forcing
+// the caller to reconstruct that metadata by hand.
+type PodSpecDump struct {
+	ID               string
+	Namespace        string
+	Name             string
+	KubeName         string
+	LogDir           string
+	Labels           map[string]string
+	KubeAnnotations  map[string]string
+	ProcessLabel     string
+	MountLabel       string
+	Metadata         *pb.PodSandboxMetadata
+	ShmPath          string
+	CgroupParent     string
+	Privileged       bool
+	RuntimeHandler   string
+	ResolvPath       string
+	Hostname         string
+	PortMappings     []*hostport.PortMapping
+	HostNetwork      bool
+	NamespaceOptions *pb.NamespaceOption
+	// ManagedNamespaces records the NSTypes this sandbox had privately
+	// pinned at checkpoint time, so Restore can re-pin exactly that set
+	// via CreateManagedNamespacesWithSpecs instead of guessing at a fixed
+	// list that predates later namespace types like PIDNS/TIMENS/CGROUPNS.
+	ManagedNamespaces []NSType
+	// Containers lists every checkpointed container in dependency order,
+	// infra container first.
+	Containers []ContainerSpec
+}
+
+// Compression selects the archive compression used for a sandbox
+// checkpoint. It mirrors containers/storage/pkg/archive.Compression rather
+// than reusing it directly, so callers outside this package aren't forced
+// to import containers/storage just to build a CheckpointOptions.
+type Compression int
+
+const (
+	// unsetCompression is the zero value of Compression, distinct from
+	// None so that Checkpoint can tell an explicit request for no
+	// compression apart from a caller that never touched the field.
+	unsetCompression Compression = iota
+	// None writes an uncompressed tar, trading archive size for skipping
+	// the CPU cost of compression on fast local snapshots.
+	None
+	// Gzip is the most widely compatible option, at the cost of both
+	// size and speed relative to Zstd.
+	Gzip
+	// Zstd is the default: smaller archives than Gzip at comparable
+	// speed.
+	Zstd
+)
+
+func (c Compression) storageCompression() archive.Compression {
+	switch c {
+	case Gzip:
+		return archive.Gzip
+	case Zstd:
+		return archive.Zstd
+	default:
+		return archive.Uncompressed
+	}
+}
+
+// CheckpointOptions configures a Sandbox.Checkpoint call.
+type CheckpointOptions struct {
+	// Archive is the path the resulting tar is written to.
+	Archive string
+	// Compression selects the archive's compression. Defaults to Zstd.
+	Compression Compression
+	// TCPEstablished checkpoints a container with established TCP
+	// connections rather than failing out.
+	TCPEstablished bool
+	// Keep leaves the temporary checkpoint image directory in place
+	// instead of removing it once Archive has been written.
+	Keep bool
+}
+
+// RestoreOptions configures a Sandbox.Restore call.
+type RestoreOptions struct {
+	// TCPEstablished restores a container with established TCP
+	// connections rather than failing out.
+	TCPEstablished bool
+	// Keep leaves the temporary restore image directory in place
+	// instead of removing it once the sandbox is running.
+	Keep bool
+}
+
+// Checkpoint freezes the sandbox's infra container plus every container
+// running inside it, and its pinned managed namespaces, into a single
+// portable archive that Restore can later bring back up on the same or a
+// different node.
+func (s *Sandbox) Checkpoint(ctx context.Context, opts CheckpointOptions) (archivePath string, retErr error) {
+	compression := opts.Compression
+	if compression == unsetCompression {
+		compression = Zstd
+	}
+
+	dumpDir, err := ioutil.TempDir("", "crio-sandbox-checkpoint-")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create checkpoint staging directory")
+	}
+	if !opts.Keep {
+		defer func() {
+			if err := os.RemoveAll(dumpDir); err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+	}
+
+	spec := s.podSpecDump()
+
+	if s.InfraContainer() != nil {
+		if err := s.runtime.CheckpointContainer(ctx, s.InfraContainer(), filepath.Join(dumpDir, "infra"), opts.TCPEstablished); err != nil {
+			return "", errors.Wrap(err, "failed to checkpoint infra container")
+		}
+		spec.Containers = append(spec.Containers, containerSpecOf(s.InfraContainer(), true))
+	}
+	for _, ctr := range s.Containers().List() {
+		if err := s.runtime.CheckpointContainer(ctx, ctr, filepath.Join(dumpDir, ctr.ID()), opts.TCPEstablished); err != nil {
+			return "", errors.Wrapf(err, "failed to checkpoint container %s", ctr.ID())
+		}
+		spec.Containers = append(spec.Containers, containerSpecOf(ctr, false))
+	}
+
+	specData, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal pod spec manifest")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dumpDir, podSpecDumpFile), specData, 0o644); err != nil {
+		return "", errors.Wrap(err, "failed to write pod spec manifest")
+	}
+
+	archivePath = opts.Archive
+	if archivePath == "" {
+		// Outside dumpDir, so the archive neither ends up inside itself
+		// nor is removed with the staging directory.
+		archivePath = filepath.Join(filepath.Dir(dumpDir), s.ID()+".tar")
+	}
+
+	reader, err := archive.TarWithOptions(dumpDir, &archive.TarOptions{
+		Compression: compression.storageCompression(),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to archive sandbox checkpoint")
+	}
+	defer reader.Close()
+
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create checkpoint archive")
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, reader); err != nil {
+		return "", errors.Wrap(err, "failed to write checkpoint archive")
+	}
+	return archivePath, nil
+}
+
+// Restore unpacks a sandbox checkpoint archive written by Checkpoint,
+// sniffing its compression rather than trusting the caller, re-pins the
+// sandbox's managed namespaces via CreateManagedNamespacesWithSpecs (using
+// the set Checkpoint recorded in the pod spec manifest, not a fixed list),
+// and restores each member container via CRIU.
+func (s *Sandbox) Restore(ctx context.Context, archivePath string, opts RestoreOptions) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open checkpoint archive")
+	}
+	defer f.Close()
+
+	restoreDir, err := ioutil.TempDir("", "crio-sandbox-restore-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create restore staging directory")
+	}
+	if !opts.Keep {
+		defer os.RemoveAll(restoreDir) // nolint:errcheck
+	}
+
+	// archive.Untar sniffs and transparently decompresses None, Gzip, or
+	// Zstd, regardless of what the caller requested at checkpoint time.
+	if err := archive.Untar(f, restoreDir, &archive.TarOptions{}); err != nil {
+		return errors.Wrap(err, "failed to unpack checkpoint archive")
+	}
+
+	restoredSpecData, err := ioutil.ReadFile(filepath.Join(restoreDir, podSpecDumpFile))
+	if err != nil {
+		return errors.Wrap(err, "failed to read pod spec manifest")
+	}
+	var restoredSpec PodSpecDump
+	if err := json.Unmarshal(restoredSpecData, &restoredSpec); err != nil {
+		return errors.Wrap(err, "failed to unmarshal pod spec manifest")
+	}
+
+	restoredNamespaces := make([]NamespaceSpec, 0, len(restoredSpec.ManagedNamespaces))
+	for _, nsType := range restoredSpec.ManagedNamespaces {
+		restoredNamespaces = append(restoredNamespaces, NamespaceSpec{Type: nsType, Mode: Private})
+	}
+	if _, err := s.CreateManagedNamespacesWithSpecs(restoredNamespaces, nil); err != nil {
+		return errors.Wrap(err, "failed to re-pin sandbox namespaces")
+	}
+
+	if infraDump := filepath.Join(restoreDir, "infra"); dirExists(infraDump) {
+		if err := s.runtime.RestoreContainer(ctx, s.InfraContainer(), infraDump, opts.TCPEstablished); err != nil {
+			return errors.Wrap(err, "failed to restore infra container")
+		}
+	}
+	for _, ctr := range s.Containers().List() {
+		ctrDump := filepath.Join(restoreDir, ctr.ID())
+		if !dirExists(ctrDump) {
+			continue
+		}
+		if err := s.runtime.RestoreContainer(ctx, ctr, ctrDump, opts.TCPEstablished); err != nil {
+			return errors.Wrapf(err, "failed to restore container %s", ctr.ID())
+		}
+	}
+	return nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// NamespaceOptions returns the NamespaceOption SetNamespaceOptions last
+// recorded for this sandbox.
+func (s *Sandbox) NamespaceOptions() *pb.NamespaceOption {
+	return s.nsOpts
+}
+
+// ManagedNamespaces returns the NSTypes this sandbox currently has privately
+// pinned (i.e. created with NamespaceMode Private), in no particular order.
+// Checkpoint records the result into PodSpecDump so Restore can re-pin
+// exactly this set instead of assuming a fixed list of namespace types.
+func (s *Sandbox) ManagedNamespaces() []NSType {
+	types := make([]NSType, 0, len(s.namespaces))
+	for nsType := range s.namespaces {
+		types = append(types, nsType)
+	}
+	return types
+}
+
+// podSpecDump captures everything Checkpoint needs to later recreate this
+// sandbox from scratch, aside from the member containers Checkpoint appends
+// as it goes.
+func (s *Sandbox) podSpecDump() *PodSpecDump {
+	return &PodSpecDump{
+		ID:                s.ID(),
+		Namespace:         s.Namespace(),
+		Name:              s.Name(),
+		KubeName:          s.KubeName(),
+		LogDir:            s.LogDir(),
+		Labels:            s.Labels(),
+		KubeAnnotations:   s.Annotations(),
+		ProcessLabel:      s.ProcessLabel(),
+		MountLabel:        s.MountLabel(),
+		Metadata:          s.Metadata(),
+		ShmPath:           s.ShmPath(),
+		CgroupParent:      s.CgroupParent(),
+		Privileged:        s.Privileged(),
+		RuntimeHandler:    s.RuntimeHandler(),
+		ResolvPath:        s.ResolvPath(),
+		Hostname:          s.Hostname(),
+		PortMappings:      s.PortMappings(),
+		HostNetwork:       s.HostNetwork(),
+		NamespaceOptions:  s.NamespaceOptions(),
+		ManagedNamespaces: s.ManagedNamespaces(),
+	}
+}
+
+// containerSpecOf snapshots ctr into a ContainerSpec, mirroring how
+// BoltState persists an oci.Container so a restored pod can reconstruct the
+// same container without reparsing its config.json.
+func containerSpecOf(ctr *oci.Container, isInfra bool) ContainerSpec {
+	return ContainerSpec{
+		ID:              ctr.ID(),
+		Name:            ctr.Name(),
+		BundlePath:      ctr.BundlePath(),
+		LogPath:         ctr.LogPath(),
+		Labels:          ctr.Labels(),
+		Annotations:     ctr.Annotations(),
+		KubeAnnotations: ctr.KubeAnnotations(),
+		Image:           ctr.Image(),
+		ImageName:       ctr.ImageName(),
+		ImageRef:        ctr.ImageRef(),
+		Metadata:        ctr.Metadata(),
+		Terminal:        ctr.Terminal(),
+		Stdin:           ctr.Stdin(),
+		StdinOnce:       ctr.StdinOnce(),
+		RuntimeHandler:  ctr.RuntimeHandler(),
+		Dir:             ctr.Dir(),
+		Created:         ctr.CreatedAt(),
+		StopSignal:      ctr.StopSignal(),
+		IsInfra:         isInfra,
+	}
+}
+
+// ReadPodSpecDump reads the pod.spec.dump manifest out of a checkpoint
+// archive without restoring anything, so a caller that doesn't have the
+// original Sandbox in memory (e.g. after a crio restart, or on a different
+// node) can learn enough to recreate it before calling Restore.
+func ReadPodSpecDump(archivePath string) (*PodSpecDump, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open checkpoint archive")
+	}
+	defer f.Close()
+
+	tmpDir, err := ioutil.TempDir("", "crio-pod-spec-dump-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create staging directory")
+	}
+	defer os.RemoveAll(tmpDir) // nolint:errcheck
+
+	if err := archive.Untar(f, tmpDir, &archive.TarOptions{}); err != nil {
+		return nil, errors.Wrap(err, "failed to unpack checkpoint archive")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(tmpDir, podSpecDumpFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read pod spec manifest")
+	}
+
+	var spec PodSpecDump
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal pod spec manifest")
+	}
+	return &spec, nil
+}
@@ -0,0 +1,95 @@
+package sandbox_test
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/cri-o/cri-o/internal/lib/sandbox"
+	sandboxmock "github.com/cri-o/cri-o/test/mocks/sandbox"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// assertArchiveCompression sniffs archivePath's compression and asserts it
+// matches want, so a test can tell Checkpoint actually honored the
+// requested Compression rather than just succeeding.
+func assertArchiveCompression(archivePath string, want sandbox.Compression) {
+	data, err := ioutil.ReadFile(archivePath)
+	Expect(err).To(BeNil())
+
+	got := archive.DetectCompression(data)
+	switch want {
+	case sandbox.None:
+		Expect(got).To(Equal(archive.Uncompressed))
+	case sandbox.Gzip:
+		Expect(got).To(Equal(archive.Gzip))
+	case sandbox.Zstd:
+		Expect(got).To(Equal(archive.Zstd))
+	}
+}
+
+var _ = t.Describe("CheckpointRestore", func() {
+	BeforeEach(beforeEach)
+
+	It("should pin and remove managed namespaces with the mock functor", func() {
+		// Given
+		getPath := pinNamespacesFunctor{
+			ifaceModifyFunc: func(ifaceMock *sandboxmock.MockNamespaceIface) {
+				setPathToDir(genericNamespaceParentDir, ifaceMock)
+			},
+		}
+		_, err := testSandbox.CreateNamespacesWithFunc(allManagedNamespaces, idMappings, nil, nil, getPath.pinNamespaces)
+		Expect(err).To(BeNil())
+
+		// When
+		err = testSandbox.RemoveManagedNamespaces()
+
+		// Then
+		Expect(err).To(BeNil())
+	})
+
+	It("should default Compression to Zstd", func() {
+		// Given
+		opts := sandbox.CheckpointOptions{}
+
+		// When
+		archivePath, err := testSandbox.Checkpoint(context.Background(), opts)
+
+		// Then
+		Expect(err).To(BeNil())
+		assertArchiveCompression(archivePath, sandbox.Zstd)
+	})
+
+	It("should not compress the archive when Compression is explicitly None", func() {
+		// Given
+		opts := sandbox.CheckpointOptions{Compression: sandbox.None}
+
+		// When
+		archivePath, err := testSandbox.Checkpoint(context.Background(), opts)
+
+		// Then
+		Expect(err).To(BeNil())
+		assertArchiveCompression(archivePath, sandbox.None)
+	})
+
+	It("should fail to restore a nonexistent archive", func() {
+		// Given
+		opts := sandbox.RestoreOptions{}
+
+		// When
+		err := testSandbox.Restore(context.Background(), "/does/not/exist.tar", opts)
+
+		// Then
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should fail to read the pod spec manifest of a nonexistent archive", func() {
+		// When
+		spec, err := sandbox.ReadPodSpecDump("/does/not/exist.tar")
+
+		// Then
+		Expect(err).NotTo(BeNil())
+		Expect(spec).To(BeNil())
+	})
+})
@@ -0,0 +1,97 @@
+package sandbox_test
+
+import (
+	"github.com/cri-o/cri-o/internal/lib/sandbox"
+	"github.com/cri-o/cri-o/internal/oci"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = t.Describe("NamespaceModes", func() {
+	BeforeEach(beforeEach)
+
+	It("should resolve a Host namespace to the host proc path", func() {
+		// Given
+		specs := []sandbox.NamespaceSpec{{Type: "net", Mode: sandbox.Host}}
+
+		// When
+		ifaces, err := testSandbox.CreateManagedNamespacesWithSpecs(specs, oci.NewMemoryStore())
+
+		// Then
+		Expect(err).To(BeNil())
+		Expect(ifaces[0].Get().Path()).To(Equal("/proc/self/ns/net"))
+	})
+
+	It("should resolve a Path namespace to the caller-supplied path", func() {
+		// Given
+		specs := []sandbox.NamespaceSpec{{Type: "net", Mode: sandbox.Path, Value: "/proc/self/ns/net"}}
+
+		// When
+		ifaces, err := testSandbox.CreateManagedNamespacesWithSpecs(specs, oci.NewMemoryStore())
+
+		// Then
+		Expect(err).To(BeNil())
+		Expect(ifaces[0].Get().Path()).To(Equal("/proc/self/ns/net"))
+	})
+
+	It("should fail to resolve FromContainer for a missing container", func() {
+		// Given
+		specs := []sandbox.NamespaceSpec{{Type: "net", Mode: sandbox.FromContainer, Value: "doesnotexist"}}
+
+		// When
+		_, err := testSandbox.CreateManagedNamespacesWithSpecs(specs, oci.NewMemoryStore())
+
+		// Then
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should resolve FromContainer to the named container's proc path", func() {
+		// Given
+		setupInfraContainerWithPid(1)
+		infraContainers := oci.NewMemoryStore()
+		Expect(infraContainers.Add("testid", testSandbox.InfraContainer())).To(BeNil())
+		specs := []sandbox.NamespaceSpec{{Type: "net", Mode: sandbox.FromContainer, Value: "testid"}}
+
+		// When
+		ifaces, err := testSandbox.CreateManagedNamespacesWithSpecs(specs, infraContainers)
+
+		// Then
+		Expect(err).To(BeNil())
+		Expect(ifaces[0].Get().Path()).To(Equal("/proc/1/ns/net"))
+	})
+
+	It("should resolve FromPod to the sandbox's own infra container", func() {
+		// Given
+		setupInfraContainerWithPid(1)
+		specs := []sandbox.NamespaceSpec{{Type: "net", Mode: sandbox.FromPod}}
+
+		// When
+		ifaces, err := testSandbox.CreateManagedNamespacesWithSpecs(specs, oci.NewMemoryStore())
+
+		// Then
+		Expect(err).To(BeNil())
+		Expect(ifaces[0].Get().Path()).To(Equal("/proc/1/ns/net"))
+	})
+
+	It("should fail to resolve FromPod without an infra container", func() {
+		// Given
+		specs := []sandbox.NamespaceSpec{{Type: "net", Mode: sandbox.FromPod}}
+
+		// When
+		_, err := testSandbox.CreateManagedNamespacesWithSpecs(specs, oci.NewMemoryStore())
+
+		// Then
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should fail on an empty Path value", func() {
+		// Given
+		specs := []sandbox.NamespaceSpec{{Type: "net", Mode: sandbox.Path}}
+
+		// When
+		_, err := testSandbox.CreateManagedNamespacesWithSpecs(specs, oci.NewMemoryStore())
+
+		// Then
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -26,6 +26,11 @@ type Namespace struct {
 	initialized bool
 	nsType      NSType
 	nsPath      string
+	// placeholderPid is the PID of the process pinPIDNamespace started to
+	// hold this namespace open, or 0 for namespaces pinned by pinns
+	// instead. Remove kills it so a PID namespace doesn't leak a
+	// permanent zombie-holder process once it is no longer needed.
+	placeholderPid int
 }
 
 // NS is a wrapper for the containernetworking plugin's NetNS interface
@@ -57,10 +62,12 @@ func (n *Namespace) Initialize() NamespaceIface {
 // representing that namespace, without switching to it
 func pinNamespaces(nsTypes []NSType, cfg *config.Config) ([]NamespaceIface, error) {
 	typeToArg := map[NSType]string{
-		IPCNS:  "-i",
-		UTSNS:  "-u",
-		USERNS: "-U",
-		NETNS:  "-n",
+		IPCNS:    "-i",
+		UTSNS:    "-u",
+		USERNS:   "-U",
+		NETNS:    "-n",
+		TIMENS:   "-t",
+		CGROUPNS: "-c",
 	}
 
 	pinnedNamespace := uuid.New().String()
@@ -122,6 +129,26 @@ func pinNamespaces(nsTypes []NSType, cfg *config.Config) ([]NamespaceIface, erro
 	return returnedNamespaces, nil
 }
 
+// SetTimeNsOffsets writes the per-pod monotonic/boottime clock offsets
+// requested via the io.kubernetes.cri-o.timens-offsets annotation into the
+// pinned time namespace's timens_offsets file. This must happen before any
+// container joins the namespace: the kernel only honors timens_offsets
+// writes made before the namespace's first process has been created, which
+// is why it is done here, right after pinning, rather than at container
+// start.
+func SetTimeNsOffsets(nsPath string, monotonicOffsetSec, bootTimeOffsetSec int64) error {
+	ns, err := nspkg.GetNS(nsPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to open time namespace")
+	}
+	defer ns.Close()
+
+	offsets := fmt.Sprintf("monotonic %d 0\nboottime %d 0\n", monotonicOffsetSec, bootTimeOffsetSec)
+	return ns.Do(func(_ nspkg.NetNS) error {
+		return os.WriteFile("/proc/self/timens_offsets", []byte(offsets), 0o644)
+	})
+}
+
 // getNamespace takes a path, checks if it is a namespace, and if so
 // returns a Namespace
 func getNamespace(nsPath string) (*Namespace, error) {
@@ -185,5 +212,19 @@ func (n *Namespace) Remove() error {
 		}
 	}
 
+	if n.placeholderPid != 0 {
+		if err := unix.Kill(n.placeholderPid, unix.SIGKILL); err != nil && err != unix.ESRCH {
+			return errors.Wrapf(err, "failed to kill pid namespace placeholder process %d", n.placeholderPid)
+		}
+		// The placeholder is started as a direct child of this process
+		// (pinPIDNamespace's cmd.Start()), not reparented to init, so
+		// nothing else reaps it once killed. Without this Wait4, it sits
+		// as a zombie under crio for the rest of the daemon's life.
+		var ws unix.WaitStatus
+		if _, err := unix.Wait4(n.placeholderPid, &ws, 0, nil); err != nil && err != unix.ECHILD {
+			return errors.Wrapf(err, "failed to reap pid namespace placeholder process %d", n.placeholderPid)
+		}
+	}
+
 	return nil
 }
@@ -320,6 +320,42 @@ var _ = t.Describe("SandboxManagedNamespaces", func() {
 			// When
 			err := testSandbox.UserNsJoin("/tmp")
 
+			// Then
+			Expect(err).NotTo(BeNil())
+		})
+		It("should succeed when asked to join a pid namespace", func() {
+			// Given
+			err := testSandbox.PidNsJoin("/proc/self/ns/pid")
+
+			// Then
+			Expect(err).To(BeNil())
+		})
+		It("should fail when pid namespace not exists", func() {
+			// Given
+			// When
+			err := testSandbox.PidNsJoin("path")
+
+			// Then
+			Expect(err).NotTo(BeNil())
+		})
+		It("should fail when sandbox already has pid namespace", func() {
+			// Given
+			managedNamespaces := []nsmgr.NSType{"pid"}
+
+			successful := newGenericFunctor()
+			// When
+			_, err := testSandbox.CreateNamespacesWithFunc(managedNamespaces, idMappings, nil, nil, successful.pinNamespaces)
+			Expect(err).To(BeNil())
+			err = testSandbox.PidNsJoin("/proc/self/ns/pid")
+
+			// Then
+			Expect(err).NotTo(BeNil())
+		})
+		It("should fail when asked to join a non-namespace", func() {
+			// Given
+			// When
+			err := testSandbox.PidNsJoin("/tmp")
+
 			// Then
 			Expect(err).NotTo(BeNil())
 		})
@@ -360,6 +396,23 @@ var _ = t.Describe("SandboxManagedNamespaces", func() {
 			// Then
 			Expect(ns).To(Equal(""))
 		})
+		It("should prefer a managed pid namespace over the infra-derived path", func() {
+			// Given
+			setupInfraContainerWithPid(1)
+			managedNamespaces := []nsmgr.NSType{"pid"}
+			getPath := pinNamespacesFunctor{
+				ifaceModifyFunc: func(ifaceMock *sandboxmock.MockNamespaceIface) {
+					setPathToDir(genericNamespaceParentDir, ifaceMock)
+				},
+			}
+
+			// When
+			_, err := testSandbox.CreateNamespacesWithFunc(managedNamespaces, idMappings, nil, nil, getPath.pinNamespaces)
+			Expect(err).To(BeNil())
+
+			// Then
+			Expect(testSandbox.PidNsPath()).NotTo(ContainSubstring("/proc"))
+		})
 		It("should get something when network is set", func() {
 			// Given
 			managedNamespaces := []nsmgr.NSType{"net"}
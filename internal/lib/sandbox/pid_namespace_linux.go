@@ -0,0 +1,79 @@
+// +build linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cri-o/cri-o/pkg/config"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// PIDNS is the managed namespace type for a sandbox's PID namespace. Unlike
+// NET/IPC/UTS/USER, pinns has no flag for it, so pinning it is handled
+// separately by pinPIDNamespace below rather than through the generic
+// typeToArg path in pinNamespaces.
+const PIDNS NSType = "pid"
+
+// pinPIDNamespace creates a new PID namespace by unsharing it in a
+// long-lived placeholder process and bind-mounting that process's
+// /proc/<pid>/ns/pid into cfg.NamespacesDir, so the namespace survives
+// infra-container restarts and can be joined by later exec/attach paths the
+// same way NETNS already is.
+//
+// A PID namespace has no persistent handle of its own the way a network
+// namespace does: it stays alive only as long as some process lives inside
+// it (or a bind mount pins that process's ns file), which is why this needs
+// a dedicated placeholder rather than the usual pinns invocation.
+func pinPIDNamespace(cfg *config.Config) (NamespaceIface, error) {
+	pinnedNamespace := uuid.New().String()
+	nsDir := filepath.Join(cfg.NamespacesDir, "pidns")
+	if err := os.MkdirAll(nsDir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create pid namespace directory")
+	}
+	nsPath := filepath.Join(nsDir, pinnedNamespace)
+
+	cmd := exec.Command("sleep", "infinity")
+	cmd.SysProcAttr = &unix.SysProcAttr{
+		Cloneflags: unix.CLONE_NEWPID,
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "failed to start PID namespace placeholder process")
+	}
+
+	childNsPath := filepath.Join("/proc", strconv.Itoa(cmd.Process.Pid), "ns", "pid")
+	if err := bindMountNamespace(childNsPath, nsPath); err != nil {
+		_ = cmd.Process.Kill() // nolint:errcheck
+		return nil, err
+	}
+
+	ns, err := getNamespace(nsPath)
+	if err != nil {
+		_ = cmd.Process.Kill() // nolint:errcheck
+		return nil, err
+	}
+	ns.nsType = PIDNS
+	ns.placeholderPid = cmd.Process.Pid
+	return ns, nil
+}
+
+// bindMountNamespace bind-mounts src (a /proc/<pid>/ns/pid entry) onto an
+// empty file at dst, so the namespace stays pinned even after the
+// placeholder process that originally held it open exits.
+func bindMountNamespace(src, dst string) error {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_RDONLY, 0o444)
+	if err != nil {
+		return errors.Wrap(err, "failed to create namespace bind-mount target")
+	}
+	f.Close()
+
+	if err := unix.Mount(src, dst, "none", unix.MS_BIND, ""); err != nil {
+		return errors.Wrapf(err, "failed to bind mount %s to %s", src, dst)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package shutdown implements the clean-shutdown marker crio uses to tell
+// a crash apart from a graceful stop across a restart, independent of the
+// existing tmpfs/persistent VersionFile reboot and upgrade detection in
+// cmd/crio.
+package shutdown
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// WriteMarker creates the clean-shutdown marker at path. cmd/crio calls it
+// at the end of catchShutdown's graceful path, once every listener and
+// both CRI services have torn down, so the marker's presence at the next
+// startup means this run got all the way through shutdown rather than
+// being killed or crashing.
+func WriteMarker(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "creating clean shutdown marker %s", path)
+	}
+	return f.Close()
+}
+
+// RemoveMarker deletes the clean-shutdown marker, so a crash before the
+// next clean shutdown leaves it absent. cmd/crio calls it once on every
+// successful startup. The marker already being absent, e.g. on the very
+// first boot, is not an error.
+func RemoveMarker(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "removing clean shutdown marker %s", path)
+	}
+	return nil
+}
+
+// HasMarker reports whether the clean-shutdown marker is present at path.
+func HasMarker(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// ShouldWipeContainers reports whether the wipe logic that currently keys
+// off tmpfsVersionFileExists (the reboot signal: absence means the node
+// rebooted since last startup) should also wipe containers this time.
+// hadCleanShutdown is the result of HasMarker read before it gets removed
+// for the new run. A reboot still wipes regardless of hadCleanShutdown,
+// same as today; what's new is that an unclean shutdown now also wipes
+// even when the node didn't reboot, since stale container state can't be
+// trusted after a crash any more than it can after a reboot.
+func ShouldWipeContainers(tmpfsVersionFileExists, hadCleanShutdown bool) bool {
+	if !tmpfsVersionFileExists {
+		return true
+	}
+	return !hadCleanShutdown
+}
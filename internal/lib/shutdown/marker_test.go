@@ -0,0 +1,80 @@
+package shutdown
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteRemoveHasMarker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crio.shutdown")
+
+	if has, err := HasMarker(path); err != nil || has {
+		t.Fatalf("HasMarker() = %v, %v; want false, nil", has, err)
+	}
+
+	if err := WriteMarker(path); err != nil {
+		t.Fatalf("WriteMarker() = %v; want nil", err)
+	}
+	if has, err := HasMarker(path); err != nil || !has {
+		t.Fatalf("HasMarker() = %v, %v; want true, nil", has, err)
+	}
+
+	if err := RemoveMarker(path); err != nil {
+		t.Fatalf("RemoveMarker() = %v; want nil", err)
+	}
+	if has, err := HasMarker(path); err != nil || has {
+		t.Fatalf("HasMarker() = %v, %v; want false, nil", has, err)
+	}
+
+	// Removing an already-absent marker is not an error.
+	if err := RemoveMarker(path); err != nil {
+		t.Fatalf("RemoveMarker() on absent marker = %v; want nil", err)
+	}
+}
+
+func TestShouldWipeContainers(t *testing.T) {
+	for _, tc := range []struct {
+		name                   string
+		tmpfsVersionFileExists bool
+		hadCleanShutdown       bool
+		wantWipe               bool
+	}{
+		{
+			name:                   "crash, no reboot",
+			tmpfsVersionFileExists: true,
+			hadCleanShutdown:       false,
+			wantWipe:               true,
+		},
+		{
+			name:                   "clean stop, no reboot",
+			tmpfsVersionFileExists: true,
+			hadCleanShutdown:       true,
+			wantWipe:               false,
+		},
+		{
+			name:                   "reboot after clean stop",
+			tmpfsVersionFileExists: false,
+			hadCleanShutdown:       true,
+			wantWipe:               true,
+		},
+		{
+			name:                   "reboot after crash",
+			tmpfsVersionFileExists: false,
+			hadCleanShutdown:       false,
+			wantWipe:               true,
+		},
+		{
+			name:                   "upgrade across a clean stop",
+			tmpfsVersionFileExists: true,
+			hadCleanShutdown:       true,
+			wantWipe:               false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ShouldWipeContainers(tc.tmpfsVersionFileExists, tc.hadCleanShutdown); got != tc.wantWipe {
+				t.Errorf("ShouldWipeContainers(%v, %v) = %v; want %v",
+					tc.tmpfsVersionFileExists, tc.hadCleanShutdown, got, tc.wantWipe)
+			}
+		})
+	}
+}
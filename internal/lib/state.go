@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"github.com/cri-o/cri-o/internal/lib/sandbox"
+	"github.com/cri-o/cri-o/internal/oci"
+)
+
+// State is ContainerServer's record of every sandbox and container it is
+// managing. InMemoryState is the original, purely in-process implementation;
+// BoltState additionally persists the same data to a bbolt database under
+// the storage root, so ContainerServer can rehydrate itself on restart in
+// O(N) reads instead of LoadSandbox/LoadContainer re-parsing every
+// container's on-disk config.json and its annotations. LoadSandbox and
+// LoadContainer remain as the fallback path for an ID that turns up on disk
+// but has no record in the active State, e.g. on the first restart after
+// upgrading from a State backend without that ID.
+type State interface {
+	AddSandbox(sb *sandbox.Sandbox) error
+	GetSandbox(id string) *sandbox.Sandbox
+	HasSandbox(id string) bool
+	RemoveSandbox(id string) error
+	ListSandboxes() []*sandbox.Sandbox
+	// RenameSandbox updates the persisted name of the sandbox identified
+	// by id to newName, keeping any on-disk name index consistent with
+	// the in-memory *sandbox.Sandbox the caller has already renamed via
+	// SetName. InMemoryState's implementation is a no-op, since it holds
+	// that same pointer rather than a separate persisted copy.
+	RenameSandbox(id, newName string) error
+
+	AddContainer(ctr *oci.Container) error
+	AddInfraContainer(ctr *oci.Container) error
+	GetContainer(id string) *oci.Container
+	GetInfraContainer(id string) *oci.Container
+	HasContainer(id string) bool
+	RemoveContainer(ctr *oci.Container) error
+	RemoveInfraContainer(ctr *oci.Container) error
+	ListContainers() []*oci.Container
+	// RenameContainer is RenameSandbox's container-level counterpart.
+	RenameContainer(id, newName string) error
+
+	// ReserveProcessLevel and ReleaseProcessLevel track how many sandboxes
+	// currently share an SELinux MCS level, so the level can be released
+	// once the count drops back to 0. This bookkeeping is inherently
+	// per-process, so both State implementations keep it in memory only.
+	ReserveProcessLevel(level string)
+	ReleaseProcessLevel(level string) int
+
+	// Close releases any resources (e.g. an open database handle) held by
+	// the State. InMemoryState's implementation is a no-op.
+	Close() error
+}
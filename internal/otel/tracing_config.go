@@ -2,83 +2,273 @@ package otel
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"os"
 
+	"github.com/cri-o/cri-o/internal/version"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-// InitOtelTracing configures opentelemetry exporter and tracer provider for given backend collector.
-//func InitOtelTracing(ctx context.Context, configureOtel bool, collectorPort, otelServiceName, backend string, samplingRate *int32) (
-func InitOtelTracing(ctx context.Context, configureOtel bool, collectorPort, otelServiceName, backend string) (
-	*sdktrace.TracerProvider,
-	grpc.UnaryServerInterceptor,
-	grpc.StreamServerInterceptor,
-	error,
-) {
-	if !configureOtel {
-		return nil, nil, nil, nil
-	}
-	// Maybe kubelet global TracerProvider is registered?
-	var tp *sdktrace.TracerProvider
-	var err error
+// TracingConfig holds everything needed to build a tracer provider for a
+// single backend, as parsed from crio.conf's [tracing] table.
+type TracingConfig struct {
+	// Backend selects the exporter: "stdout", "otlp" (gRPC), "otlphttp",
+	// "jaeger", or "none" to disable tracing entirely.
+	Backend string
+	// Endpoint is the collector address, e.g. "0.0.0.0:4317" for otlp or
+	// a full Jaeger collector URL.
+	Endpoint string
+	// SamplingRatePerMillion only applies to root spans; spans started
+	// because the kubelet propagated a sampled trace are always honored.
+	// 0 disables sampling (NeverSample), 1000000 always samples.
+	SamplingRatePerMillion int
+	// Headers are added to every export request, e.g. for collector auth.
+	Headers map[string]string
+	// TLS, when non-nil, is used for the otlp/otlphttp exporter connection.
+	TLS *TLSConfig
+	// Propagators lists the propagators to install, e.g. "tracecontext",
+	// "baggage". Defaults to both when empty.
+	Propagators []string
+}
+
+// TLSConfig describes the client credentials to use when talking to a
+// collector over TLS.
+type TLSConfig struct {
+	// CertFile and KeyFile, when both set, present a client certificate to
+	// the collector for mTLS. Leave both empty to authenticate the
+	// collector without presenting one.
+	CertFile string
+	KeyFile  string
+	// CAFile, when set, verifies the collector's certificate against this
+	// CA instead of the system trust store.
+	CAFile string
+}
+
+// Provider wraps the configured TracerProvider along with the gRPC
+// interceptors that should be installed on the CRI-O server, so callers
+// don't have to reach back into the otel package to wire them up.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+
+	UnaryServerInterceptor  grpc.UnaryServerInterceptor
+	StreamServerInterceptor grpc.StreamServerInterceptor
+}
+
+// Shutdown flushes any pending spans and releases the exporter's resources.
+// The server's main loop should call this during graceful shutdown.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil || p.tp == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
+
+// InitOtelTracing configures an opentelemetry exporter and tracer provider
+// for the given TracingConfig. It returns a nil Provider without error when
+// cfg.Backend is "none" (or empty), so callers can unconditionally call
+// Shutdown on the result.
+func InitOtelTracing(ctx context.Context, otelServiceName string, cfg *TracingConfig) (*Provider, error) {
+	if cfg == nil || cfg.Backend == "" || cfg.Backend == "none" {
+		return nil, nil
+	}
+
 	if len(otelServiceName) == 0 {
+		var err error
 		otelServiceName, err = os.Hostname()
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
 	}
+
+	nodeName, _ := os.Hostname()
 	res := resource.NewWithAttributes(
 		semconv.SchemaURL,
 		semconv.ServiceNameKey.String(otelServiceName),
+		semconv.ContainerRuntimeKey.String("cri-o"),
+		semconv.ContainerRuntimeVersionKey.String(version.Version),
+		semconv.HostNameKey.String(nodeName),
+	)
+
+	var (
+		tp  *sdktrace.TracerProvider
+		err error
 	)
-	address := fmt.Sprintf("0.0.0.0:%s", collectorPort)
-	switch backend {
+	switch cfg.Backend {
 	case "stdout":
-		exporter, err := stdouttrace.New((stdouttrace.WithPrettyPrint()))
+		tp, err = newStdoutProvider(cfg, res)
+	case "otlp":
+		tp, err = newOTLPGRPCProvider(ctx, cfg, res)
+	case "otlphttp":
+		tp, err = newOTLPHTTPProvider(ctx, cfg, res)
+	case "jaeger":
+		tp, err = newJaegerProvider(cfg, res)
+	default:
+		return nil, fmt.Errorf("OpenTelemetry exporter for backend %q not supported", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	propagators := newPropagators(cfg.Propagators)
+	opts := []otelgrpc.Option{otelgrpc.WithPropagators(propagators), otelgrpc.WithTracerProvider(tp)}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagators)
+
+	return &Provider{
+		tp:                      tp,
+		UnaryServerInterceptor:  otelgrpc.UnaryServerInterceptor(opts...),
+		StreamServerInterceptor: otelgrpc.StreamServerInterceptor(opts...),
+	}, nil
+}
+
+// sampler builds a ParentBased sampler around a ratio sampler derived from
+// cfg.SamplingRatePerMillion, so the ratio only applies to root spans while
+// a trace the kubelet already decided to sample is always honored.
+func sampler(cfg *TracingConfig) sdktrace.Sampler {
+	ratio := float64(cfg.SamplingRatePerMillion) / float64(1000000)
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+}
+
+func newStdoutProvider(cfg *TracingConfig, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler(cfg)),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+func newOTLPGRPCProvider(ctx context.Context, cfg *TracingConfig, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(cfg.Endpoint),
+		otlptracegrpc.WithHeaders(cfg.Headers),
+	}
+	if cfg.TLS == nil {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else {
+		creds, err := clientTLSCredentials(cfg.TLS)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
-		tp = sdktrace.NewTracerProvider(
-			sdktrace.WithBatcher(exporter),
-			sdktrace.WithResource(res),
-		)
-	case "otlp":
-		exporter, err := otlptracegrpc.New(ctx,
-			otlptracegrpc.WithEndpoint(address),
-			otlptracegrpc.WithInsecure(),
-		)
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(creds))
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler(cfg)),
+		sdktrace.WithSpanProcessor(sdktrace.NewBatchSpanProcessor(exporter)),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+func newOTLPHTTPProvider(ctx context.Context, cfg *TracingConfig, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(cfg.Endpoint),
+		otlptracehttp.WithHeaders(cfg.Headers),
+	}
+	if cfg.TLS == nil {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else {
+		tlsConfig, err := clientTLSConfig(cfg.TLS)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
-		// TODO: AlwaysSample for testing, for merge default to
-		// Only emit spans when the kubelet sends a request with a sampled trace
-		// sampler := sdktrace.NeverSample()
-		sampler := sdktrace.AlwaysSample()
-		//if samplingRate != nil && *samplingRate > 0 {
-		//sampler = sdktrace.TraceIDRatioBased(float64(*samplingRate) / float64(1000000))
-		//}
-		// batch span processor to aggregate spans before export.
-		bsp := sdktrace.NewBatchSpanProcessor(exporter)
-		tp = sdktrace.NewTracerProvider(
-			sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
-			sdktrace.WithSpanProcessor(bsp),
-			sdktrace.WithResource(res),
-		)
-	default:
-		return nil, nil, nil, fmt.Errorf("OpenTelemetry exporter for backend '%s' not supported.", backend)
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
 	}
-	tmp := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
-	opts := []otelgrpc.Option{otelgrpc.WithPropagators(tmp), otelgrpc.WithTracerProvider(tp)}
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(tmp)
-	return tp, otelgrpc.UnaryServerInterceptor(opts...), otelgrpc.StreamServerInterceptor(opts...), nil
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler(cfg)),
+		sdktrace.WithSpanProcessor(sdktrace.NewBatchSpanProcessor(exporter)),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+func newJaegerProvider(cfg *TracingConfig, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.Endpoint)))
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler(cfg)),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// clientTLSConfig builds the crypto/tls.Config for talking to a collector
+// using cfg: a client certificate for mTLS when CertFile/KeyFile are set,
+// and cfg.CAFile to verify the collector instead of the system trust store
+// when set. Both the gRPC and HTTP otlp exporters share this, since gRPC
+// wraps it in credentials.TransportCredentials while otlptracehttp takes a
+// *tls.Config directly.
+func clientTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate/key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.CAFile != "" {
+		caData, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+func clientTLSCredentials(cfg *TLSConfig) (credentials.TransportCredentials, error) {
+	tlsConfig, err := clientTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func newPropagators(names []string) propagation.TextMapPropagator {
+	if len(names) == 0 {
+		return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
 }
@@ -57,3 +57,24 @@ func status(pid int) (string, error) {
 	}
 	return string(data[i+2]), nil
 }
+
+// parentPid returns the ppid field of a process's /proc/<pid>/stat, i.e.
+// the second whitespace-separated field following the closing paren of comm
+// (the first being the state).
+func parentPid(pid int) (int, error) {
+	bytes, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, err
+	}
+	data := string(bytes)
+
+	i := strings.LastIndexByte(data, ')')
+	if i <= 2 || i >= len(data)-1 {
+		return 0, fmt.Errorf("invalid stat data (no comm): %q", data)
+	}
+	fields := strings.Fields(data[i+1:])
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid stat data (no ppid): %q", data)
+	}
+	return strconv.Atoi(fields[1])
+}
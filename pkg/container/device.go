@@ -9,12 +9,18 @@ import (
 	crioann "github.com/cri-o/cri-o/pkg/annotations"
 	"github.com/cri-o/cri-o/server/cri/types"
 	"github.com/cri-o/cri-o/utils"
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/cdi"
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/opencontainers/runc/libcontainer/devices"
 	rspec "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
 )
 
+// defaultCDISpecDirs mirrors the search path used by other CDI-aware
+// runtimes (containerd, podman) so spec files dropped by accelerator
+// vendors are picked up without additional configuration.
+var defaultCDISpecDirs = []string{"/etc/cdi", "/var/run/cdi"}
+
 func (c *container) SpecAddDevices(configuredDevices, annotationDevices []devicecfg.Device, privilegedWithoutHostDevices bool) error {
 	// First, clear the existing devices from the spec
 	c.Spec().Config.Linux.Devices = []rspec.LinuxDevice{}
@@ -40,8 +46,59 @@ func (c *container) SpecAddDevices(configuredDevices, annotationDevices []device
 		return err
 	}
 
-	// Finally, add container config devices
-	return c.specAddContainerConfigDevices()
+	// Then, add container config devices
+	if err := c.specAddContainerConfigDevices(); err != nil {
+		return err
+	}
+
+	// Finally, resolve any Container Device Interface (CDI) device
+	// references, e.g. nvidia.com/gpu=all. Privileged containers skip CDI
+	// resolution, including those that got no host devices above because
+	// privilegedWithoutHostDevices is set.
+	if c.Privileged() {
+		return nil
+	}
+	return c.specAddCDIDevices(defaultCDISpecDirs)
+}
+
+// specAddCDIDevices resolves CDI qualified device names requested via the
+// io.kubernetes.cri-o.Devices annotation or a CRI Devices entry whose
+// HostPath is itself a CDI qualified name (vendor.com/class=name), and
+// applies each device's containerEdits (device nodes, mounts, hooks, env)
+// onto the OCI spec being built.
+func (c *container) specAddCDIDevices(specDirs []string) error {
+	var names []string
+
+	if v, ok := c.Config().Annotations[crioann.CDIDevicesAnnotation]; ok {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	// A CRI Devices entry whose HostPath is itself a CDI qualified name
+	// (e.g. "nvidia.com/gpu=all") requests a CDI device rather than a real
+	// host path.
+	for _, device := range c.Config().Devices {
+		if cdi.IsQualifiedName(device.HostPath) {
+			names = append(names, device.HostPath)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	registry := cdi.GetRegistry(cdi.WithSpecDirs(specDirs...))
+	if err := registry.Refresh(); err != nil {
+		return errors.Wrap(err, "error refreshing CDI registry")
+	}
+
+	if _, err := registry.InjectDevices(c.Spec().Config, names...); err != nil {
+		return errors.Wrapf(err, "failed to inject CDI devices %v", names)
+	}
+	return nil
 }
 
 func (c *container) specAddHostDevicesIfPrivileged(privilegedWithoutHostDevices bool) error {
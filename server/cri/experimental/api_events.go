@@ -0,0 +1,29 @@
+package experimental
+
+// WatchRequest asks for every lifecycle event matching the given filters,
+// starting with whatever the server's Journal has already recorded.
+type WatchRequest struct {
+	// Types restricts delivery to these object types. Empty matches every
+	// type.
+	Types []string
+	// Statuses restricts delivery to these lifecycle transitions. Empty
+	// matches every status.
+	Statuses []string
+	// Labels restricts delivery to events whose Labels contain every
+	// key/value pair given here.
+	Labels map[string]string
+}
+
+// WatchEvent is a single lifecycle event delivered on a Watch stream.
+type WatchEvent struct {
+	Type   string
+	Status string
+	Id     string
+	Name   string
+	Image  string
+	PodId  string
+	Labels map[string]string
+	// UnixNano is the event's timestamp, in nanoseconds since the Unix
+	// epoch.
+	UnixNano int64
+}
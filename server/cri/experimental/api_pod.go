@@ -0,0 +1,32 @@
+package experimental
+
+// CheckpointPodSandboxRequest requests that every container in a pod
+// sandbox be checkpointed together into a single pod-level archive.
+type CheckpointPodSandboxRequest struct {
+	// PodSandboxId is the ID of the sandbox to checkpoint.
+	PodSandboxId string
+	// Options are shared with the single-container checkpoint path.
+	Options *CheckpointContainerOptions
+}
+
+// CheckpointPodSandboxResponse is returned once the pod archive has been
+// written to disk (or, with CommonOptions.Archive unset, left in place under
+// the container's checkpoint directory).
+type CheckpointPodSandboxResponse struct{}
+
+// RestorePodSandboxRequest requests that a pod archive produced by
+// CheckpointPodSandbox be restored, recreating the sandbox before
+// restoring its member containers.
+type RestorePodSandboxRequest struct {
+	// PodSandboxId is the ID of the original sandbox, used to resolve the
+	// archive when Options.CommonOptions.Archive is empty.
+	PodSandboxId string
+	Options      *RestoreContainerOptions
+}
+
+// RestorePodSandboxResponse reports the newly created (or reused) sandbox
+// along with the containers that were restored into it, in restore order.
+type RestorePodSandboxResponse struct {
+	PodSandboxId       string
+	RestoredContainers []string
+}
@@ -0,0 +1,15 @@
+package experimental
+
+// PreCheckpointContainerRequest asks CRI-O to take one more iteration of a
+// pre-copy memory dump for a still-running container, without freezing it.
+// Iteration 0 starts a fresh pre-dump chain under the container's runtime
+// directory; subsequent iterations produce an incremental delta against the
+// previous one.
+type PreCheckpointContainerRequest struct {
+	Id        string
+	Iteration int64
+}
+
+// PreCheckpointContainerResponse is returned once the pre-dump iteration has
+// been written to disk.
+type PreCheckpointContainerResponse struct{}
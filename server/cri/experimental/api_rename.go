@@ -0,0 +1,23 @@
+package experimental
+
+// RenameContainerRequest requests that a single container be renamed
+// without disturbing its running state.
+type RenameContainerRequest struct {
+	ContainerId string
+	NewName     string
+}
+
+// RenameContainerResponse is returned once the container's name has been
+// updated both on disk and in the server's in-memory state.
+type RenameContainerResponse struct{}
+
+// RenamePodSandboxRequest requests that a pod sandbox, and its infra
+// container, be renamed without disturbing the running pod.
+type RenamePodSandboxRequest struct {
+	PodSandboxId string
+	NewName      string
+}
+
+// RenamePodSandboxResponse is returned once the sandbox's name has been
+// updated both on disk and in the server's in-memory state.
+type RenamePodSandboxResponse struct{}
@@ -11,6 +11,7 @@ func (s *service) CheckpointContainer(ctx context.Context, req *CheckpointContai
 		ID: req.Id,
 		Options: &types.CheckpointContainerOptions{
 			LeaveRunning: req.Options.LeaveRunning,
+			Image:        req.Options.Image,
 			CommonOptions: &types.CheckpointRestoreOptions{
 				Keep:           req.Options.CommonOptions.Keep,
 				TCPEstablished: req.Options.CommonOptions.TcpEstablished,
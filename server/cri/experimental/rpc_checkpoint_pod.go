@@ -0,0 +1,30 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/cri-o/cri-o/server/cri/types"
+)
+
+// CheckpointPodSandbox checkpoints every container of a sandbox, in
+// dependency order (infra container first), into a single archive
+// containing a pod.spec.dump manifest alongside each container's CRIU images.
+func (s *service) CheckpointPodSandbox(ctx context.Context, req *CheckpointPodSandboxRequest) (res *CheckpointPodSandboxResponse, retErr error) {
+	r := &types.CheckpointPodSandboxRequest{
+		PodSandboxID: req.PodSandboxId,
+		Options: &types.CheckpointContainerOptions{
+			LeaveRunning: req.Options.LeaveRunning,
+			CommonOptions: &types.CheckpointRestoreOptions{
+				Keep:           req.Options.CommonOptions.Keep,
+				TCPEstablished: req.Options.CommonOptions.TcpEstablished,
+				Archive:        req.Options.CommonOptions.Archive,
+				Compression:    req.Options.CommonOptions.Compression,
+			},
+		},
+	}
+
+	if err := s.server.CheckpointPodSandbox(ctx, r); err != nil {
+		return nil, err
+	}
+	return &CheckpointPodSandboxResponse{}, nil
+}
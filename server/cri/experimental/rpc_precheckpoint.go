@@ -0,0 +1,23 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/cri-o/cri-o/server/cri/types"
+)
+
+// PreCheckpointContainer runs one iteration of a CRIU pre-copy dump (with
+// --pre-dump --track-mem) against a running container, leaving it running so
+// the final CheckpointContainer call only has to transfer the last memory
+// delta.
+func (s *service) PreCheckpointContainer(ctx context.Context, req *PreCheckpointContainerRequest) (res *PreCheckpointContainerResponse, retErr error) {
+	r := &types.PreCheckpointContainerRequest{
+		ID:        req.Id,
+		Iteration: req.Iteration,
+	}
+
+	if err := s.server.PreCheckpointContainer(ctx, r); err != nil {
+		return nil, err
+	}
+	return &PreCheckpointContainerResponse{}, nil
+}
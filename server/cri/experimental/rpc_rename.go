@@ -0,0 +1,36 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/cri-o/cri-o/server/cri/types"
+)
+
+// RenameContainer renames a single container in place, for operators who
+// want to keep a container kubelet garbage collection would otherwise
+// reclaim around for a post-mortem.
+func (s *service) RenameContainer(ctx context.Context, req *RenameContainerRequest) (*RenameContainerResponse, error) {
+	r := &types.RenameContainerRequest{
+		ContainerID: req.ContainerId,
+		NewName:     req.NewName,
+	}
+
+	if err := s.server.RenameContainer(ctx, r); err != nil {
+		return nil, err
+	}
+	return &RenameContainerResponse{}, nil
+}
+
+// RenamePodSandbox renames a pod sandbox, and its infra container, in
+// place.
+func (s *service) RenamePodSandbox(ctx context.Context, req *RenamePodSandboxRequest) (*RenamePodSandboxResponse, error) {
+	r := &types.RenamePodSandboxRequest{
+		PodSandboxID: req.PodSandboxId,
+		NewName:      req.NewName,
+	}
+
+	if err := s.server.RenamePodSandbox(ctx, r); err != nil {
+		return nil, err
+	}
+	return &RenamePodSandboxResponse{}, nil
+}
@@ -14,6 +14,7 @@ func (s *service) RestoreContainer(ctx context.Context, req *RestoreContainerReq
 			PodSandboxID: req.Options.PodSandboxId,
 			Labels:       req.Options.Labels,
 			Annotations:  req.Options.Annotations,
+			Image:        req.Options.Image,
 			CommonOptions: &types.CheckpointRestoreOptions{
 				Keep:           req.Options.CommonOptions.Keep,
 				TCPEstablished: req.Options.CommonOptions.TcpEstablished,
@@ -0,0 +1,37 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/cri-o/cri-o/server/cri/types"
+)
+
+// RestorePodSandbox recreates a sandbox from a pod-level checkpoint archive
+// and restores its member containers in the order recorded at checkpoint
+// time.
+func (s *service) RestorePodSandbox(ctx context.Context, req *RestorePodSandboxRequest) (res *RestorePodSandboxResponse, retErr error) {
+	r := &types.RestorePodSandboxRequest{
+		PodSandboxID: req.PodSandboxId,
+		Options: &types.RestoreContainerOptions{
+			Name:         req.Options.Name,
+			PodSandboxID: req.Options.PodSandboxId,
+			Labels:       req.Options.Labels,
+			Annotations:  req.Options.Annotations,
+			CommonOptions: &types.CheckpointRestoreOptions{
+				Keep:           req.Options.CommonOptions.Keep,
+				TCPEstablished: req.Options.CommonOptions.TcpEstablished,
+				Archive:        req.Options.CommonOptions.Archive,
+				Compression:    req.Options.CommonOptions.Compression,
+			},
+		},
+	}
+
+	response, err := s.server.RestorePodSandbox(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return &RestorePodSandboxResponse{
+		PodSandboxId:       response.PodSandboxID,
+		RestoredContainers: response.RestoredContainers,
+	}, nil
+}
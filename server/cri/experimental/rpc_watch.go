@@ -0,0 +1,29 @@
+package experimental
+
+import (
+	"github.com/cri-o/cri-o/server/cri/types"
+)
+
+// Watch streams lifecycle events matching req's filters to the client:
+// first the journal's existing history, then every newly recorded event
+// until the client disconnects.
+func (s *service) Watch(req *WatchRequest, stream RuntimeService_WatchServer) error {
+	r := &types.WatchRequest{
+		Types:    req.Types,
+		Statuses: req.Statuses,
+		Labels:   req.Labels,
+	}
+
+	return s.server.WatchEvents(stream.Context(), r, func(ev *types.Event) error {
+		return stream.Send(&WatchEvent{
+			Type:     ev.Type,
+			Status:   ev.Status,
+			Id:       ev.ID,
+			Name:     ev.Name,
+			Image:    ev.Image,
+			PodId:    ev.PodID,
+			Labels:   ev.Labels,
+			UnixNano: ev.UnixNano,
+		})
+	})
+}
@@ -0,0 +1,258 @@
+// +build linux
+
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containers/podman/v2/pkg/cgroups"
+	"github.com/containers/podman/v2/pkg/rootless"
+	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// RunUnderSystemdScope adds the specified PID to a new transient, delegated
+// systemd scope, so the caller's subsequent child-cgroup creation under it
+// is left alone by systemd's own cgroup bookkeeping.
+func RunUnderSystemdScope(pid int, slice, unitName string) error {
+	var conn *systemdDbus.Conn
+	var err error
+	if rootless.IsRootless() {
+		conn, err = cgroups.GetUserConnection(rootless.GetRootlessUID())
+	} else {
+		conn, err = systemdDbus.New()
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	properties := []systemdDbus.Property{
+		systemdDbus.PropSlice(slice),
+		newProp("PIDs", []uint32{uint32(pid)}),
+		newProp("Delegate", true),
+		newProp("DefaultDependencies", false),
+	}
+
+	ch := make(chan string)
+	if _, err := conn.StartTransientUnit(unitName, "replace", properties, ch); err != nil {
+		return err
+	}
+	// Block until the job is started.
+	<-ch
+	return nil
+}
+
+func newProp(name string, units interface{}) systemdDbus.Property {
+	return systemdDbus.Property{
+		Name:  name,
+		Value: dbus.MakeVariant(units),
+	}
+}
+
+// MoveUnderCgroupSubtree moves the current process under a "subtree" child
+// cgroup of its own current cgroup, enabling delegated controllers as
+// needed. On cgroup v2 this takes a single-hierarchy fast path instead of
+// looping over every v1 controller mount, honors rootless delegation by
+// resolving the writable cgroup root from /proc/self/cgroup rather than
+// assuming /sys/fs/cgroup, and creates the child via a transient systemd
+// scope (Delegate=true) when systemd is managing cgroups, since mkdir'ing
+// directly under a systemd-owned path is not guaranteed to survive scope
+// cleanup.
+func MoveUnderCgroupSubtree(subtree string) error {
+	unifiedMode, err := cgroups.IsCgroup2UnifiedMode()
+	if err != nil {
+		return err
+	}
+
+	if unifiedMode {
+		return moveUnderCgroupSubtreeUnified(subtree)
+	}
+	return moveUnderCgroupSubtreeV1(subtree)
+}
+
+// moveUnderCgroupSubtreeUnified handles the cgroup v2 case, where every
+// controller lives under a single hierarchy rooted at the process's own
+// "0::" entry in /proc/self/cgroup.
+func moveUnderCgroupSubtreeUnified(subtree string) error {
+	_, ownPath, err := ownCgroupPath()
+	if err != nil {
+		return err
+	}
+
+	cgroupRoot, err := cgroupRootFor(ownPath)
+	if err != nil {
+		return err
+	}
+
+	if IsSystemdRunning() {
+		return moveUnderSystemdScope(cgroupRoot, subtree)
+	}
+
+	if err := enableSubtreeControllers(cgroupRoot); err != nil {
+		return err
+	}
+
+	return createChildAndMove(cgroupRoot, subtree)
+}
+
+// moveUnderCgroupSubtreeV1 preserves the legacy per-controller-mount
+// behavior for hybrid/v1 hosts, where each controller is mounted under its
+// own /sys/fs/cgroup/$CONTROLLER directory.
+func moveUnderCgroupSubtreeV1(subtree string) error {
+	procFile := "/proc/self/cgroup"
+	f, err := os.Open(procFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			return errors.Errorf("cannot parse cgroup line %q", line)
+		}
+
+		// root cgroup, skip it
+		if parts[2] == "/" {
+			continue
+		}
+
+		cgroupRoot := "/sys/fs/cgroup"
+		if parts[1] == "" {
+			// Unified mount on a hybrid host, usually under .../unified.
+			cgroupRoot = filepath.Join(cgroupRoot, "unified")
+		} else {
+			controller := strings.TrimPrefix(parts[1], "name=")
+			cgroupRoot = filepath.Join(cgroupRoot, controller)
+		}
+
+		if err := createChildAndMove(filepath.Join(cgroupRoot, parts[2]), subtree); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ownCgroupPath returns the raw "0::" line and its cgroup path component
+// from /proc/self/cgroup, or an error when there is no "0::" line.
+func ownCgroupPath() (line, path string, err error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := scanner.Text()
+		parts := strings.SplitN(l, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if strings.HasPrefix(l, "0::") {
+			return l, parts[2], nil
+		}
+	}
+	return "", "", errors.New("could not find cgroup v2 entry in /proc/self/cgroup")
+}
+
+// cgroupRootFor resolves the on-disk cgroup directory for ownPath. Rootless
+// CRI-O is not granted write access to /sys/fs/cgroup itself, only to the
+// systemd-delegated scope it is already running under, so in that case we
+// walk up from ownPath until we find a directory we can actually write to.
+func cgroupRootFor(ownPath string) (string, error) {
+	full := filepath.Join("/sys/fs/cgroup", ownPath)
+	if !rootless.IsRootless() {
+		return full, nil
+	}
+
+	for dir := full; dir != "/sys/fs/cgroup" && dir != "/"; dir = filepath.Dir(dir) {
+		if unix.Access(dir, unix.W_OK) == nil {
+			return dir, nil
+		}
+	}
+	return "", errors.Errorf("no writable delegated cgroup found above %s", full)
+}
+
+// enableSubtreeControllers asks the parent cgroup to enable every
+// controller it has available on its children, via cgroup.subtree_control.
+// A child cgroup does not inherit controllers automatically on v2: a
+// controller must be explicitly delegated downward before a process moved
+// into the child can be limited by it.
+func enableSubtreeControllers(cgroupRoot string) error {
+	controllers, err := ioutil.ReadFile(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	if err != nil {
+		return errors.Wrap(err, "failed to read cgroup.controllers")
+	}
+
+	var toEnable strings.Builder
+	for _, controller := range strings.Fields(string(controllers)) {
+		toEnable.WriteString("+")
+		toEnable.WriteString(controller)
+		toEnable.WriteString(" ")
+	}
+	if toEnable.Len() == 0 {
+		return nil
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(cgroupRoot, "cgroup.subtree_control"), []byte(strings.TrimSpace(toEnable.String())), 0o644); err != nil {
+		return errors.Wrap(err, "failed to enable subtree controllers")
+	}
+	return nil
+}
+
+// createChildAndMove creates the subtree child cgroup under cgroupRoot and
+// moves the current process into it, writing the PID with the trailing
+// newline cgroup v2 requires: the kernel accepts only one PID per write, so
+// omitting it silently drops the move instead of erroring.
+func createChildAndMove(cgroupRoot, subtree string) error {
+	newCgroup := filepath.Join(cgroupRoot, subtree)
+	if err := os.MkdirAll(newCgroup, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(newCgroup, "cgroup.procs"), os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		return errors.Wrapf(err, "failed to move process into %s", newCgroup)
+	}
+	return nil
+}
+
+// moveUnderSystemdScope creates a transient, delegated systemd scope for the
+// current process instead of mkdir'ing a cgroup directly: systemd owns
+// /sys/fs/cgroup below the slice it manages, so an unmanaged mkdir there can
+// be removed out from under us the next time systemd reconciles cgroups.
+func moveUnderSystemdScope(cgroupRoot, subtree string) error {
+	unitName := fmt.Sprintf("crio-%s-%d.scope", subtree, os.Getpid())
+	if err := RunUnderSystemdScope(os.Getpid(), "system.slice", unitName); err != nil {
+		logrus.Warnf("Failed to move process into systemd scope %s, falling back to direct cgroup move: %v", unitName, err)
+		return createChildAndMove(cgroupRoot, subtree)
+	}
+	return nil
+}
+
+// IsSystemdRunning reports whether the host is managed by systemd, the
+// signal CRI-O already uses elsewhere to decide whether cgroup management
+// should be delegated through systemd scopes rather than manipulated
+// directly.
+func IsSystemdRunning() bool {
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}